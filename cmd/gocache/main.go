@@ -1,19 +1,77 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"flag"
+	"fmt"
 	"gocache/cmd/server"
+	"gocache/internal/auth"
 	"gocache/internal/cache"
+	"gocache/internal/cluster"
+	"gocache/internal/snapshot"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
 
 const defaultShardCount = 32
 
+// loadTLSConfig builds a server TLS config from a cert/key pair, optionally
+// requiring and verifying client certificates against clientCAPath (mTLS).
+func loadTLSConfig(certPath, keyPath, clientCAPath string) (*tls.Config, error) {
+	if certPath == "" || keyPath == "" {
+		return nil, errors.New("both --tls-cert and --tls-key are required to enable TLS")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading certificate/key: %w", err)
+	}
+
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if clientCAPath != "" {
+		caBytes, err := os.ReadFile(clientCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA bundle: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in %s", clientCAPath)
+		}
+
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// parsePeers parses "id@host:port,id@host:port,..." into a raft peer map.
+func parsePeers(s string) (map[uint64]string, error) {
+	peers := make(map[uint64]string)
+	for _, entry := range strings.Split(s, ",") {
+		idAddr := strings.SplitN(entry, "@", 2)
+		if len(idAddr) != 2 {
+			return nil, fmt.Errorf("peer %q is not in \"id@host:port\" form", entry)
+		}
+		id, err := strconv.ParseUint(idAddr[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("peer %q has a non-numeric id: %w", entry, err)
+		}
+		peers[id] = idAddr[1]
+	}
+	return peers, nil
+}
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
@@ -22,11 +80,49 @@ func main() {
 	port := flag.String("port", "6379", "Port to listen on")
 	shards := flag.Int("shards", defaultShardCount, "Number of shards to use")
 	maxSize := flag.Int("max-size", 10000, "Max number of items in cache (total)")
+	maxBytesStr := flag.String("max-bytes", "", "Max total byte budget for cache (total), e.g. \"64MB\" (0/empty disables)")
+	l2Dir := flag.String("l2-dir", "", "Base directory for the on-disk L2 overflow store (empty disables L2)")
+	l2MaxBytesStr := flag.String("l2-max-bytes", "", "Max total byte budget for the L2 store, e.g. \"1GB\" (empty disables the cap)")
 	cleanupInterval := flag.Duration("cleanup-interval", 10*time.Second, "Interval for cleaning up expired keys")
+	replicationBrokers := flag.String("replication-brokers", "", "Comma-separated Kafka brokers for cross-node replication (empty disables replication)")
+	replicationTopic := flag.String("replication-topic", "gocache-replication", "Kafka topic the replication log is written to")
+	replicationPublishTimeout := flag.Duration("replication-publish-timeout", 5*time.Second, "Max time to wait for a replicated write to reach Kafka before giving up")
+	nodeID := flag.String("node-id", "", "Unique ID for this node, used to skip its own writes on replay (required with --replication-brokers)")
+	snapshotDSN := flag.String("snapshot-dsn", "", "DSN for periodic snapshots, e.g. \"file:///var/lib/gocache/snapshots\" or \"s3://bucket/prefix?codec=json\" (empty disables periodic snapshots)")
+	snapshotInterval := flag.Duration("snapshot-interval", 5*time.Minute, "Interval between periodic snapshots")
+	clusterID := flag.Uint64("cluster-id", 0, "This node's raft ID (required with --peers)")
+	clusterListenAddr := flag.String("cluster-listen", "", "host:port this node's raft transport listens on (required with --peers)")
+	peersFlag := flag.String("peers", "", "Comma-separated raft peers as id@host:port (empty disables strongly-consistent clustering)")
+	raftDir := flag.String("raft-dir", "", "Directory for persisting raft hard state, log entries and snapshots; empty keeps raft storage purely in-memory")
+	tlsCert := flag.String("tls-cert", "", "Path to a TLS certificate (required with --tls-key to enable TLS)")
+	tlsKey := flag.String("tls-key", "", "Path to the TLS certificate's private key")
+	tlsClientCA := flag.String("tls-client-ca", "", "Path to a CA bundle clients must present a certificate signed by (enables mTLS; empty disables client cert verification)")
+	usersFile := flag.String("users-file", "", "Path to a bcrypt-hashed users file (empty disables authentication); reloaded on SIGHUP")
+	auditSink := flag.String("audit-sink", "stderr", "Where to send the authn/authz audit log: \"stderr\" or \"kafka\" (only used with --users-file)")
+	auditKafkaBrokers := flag.String("audit-kafka-brokers", "", "Comma-separated Kafka brokers for the audit log (required when --audit-sink=kafka)")
+	auditKafkaTopic := flag.String("audit-kafka-topic", "gocache-audit", "Kafka topic the audit log is written to")
 
 	flag.Parse()
 
-	c := cache.NewShardedCache(uint32(*shards), *maxSize, *cleanupInterval)
+	maxBytes, err := cache.ParseByteSize(*maxBytesStr)
+	if err != nil {
+		log.Fatalf("Invalid --max-bytes: %v", err)
+	}
+
+	l2MaxBytes, err := cache.ParseByteSize(*l2MaxBytesStr)
+	if err != nil {
+		log.Fatalf("Invalid --l2-max-bytes: %v", err)
+	}
+
+	c := cache.NewShardedCache(uint32(*shards), *maxSize, maxBytes, *cleanupInterval)
+
+	if *l2Dir != "" {
+		l2Store := cache.NewFSStore(*l2Dir, uint32(*shards))
+		if err := l2Store.SetMaxBytes(l2MaxBytes); err != nil {
+			log.Fatalf("Failed to initialize L2 store: %v", err)
+		}
+		c.UseSecondaryStore(l2Store)
+	}
 
 	if err := c.LoadFromFile("dump.goc"); err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
@@ -40,7 +136,132 @@ func main() {
 		log.Println("Cache data loaded from dump.gob.")
 	}
 
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	if *snapshotDSN != "" {
+		store, codec, err := snapshot.NewStoreFromDSN(*snapshotDSN)
+		if err != nil {
+			log.Fatalf("Invalid --snapshot-dsn: %v", err)
+		}
+
+		c.UseSnapshotStore(store, cache.NewSnapshotter(c, codec))
+
+		if err := c.LoadLatestSnapshot(backgroundCtx); err != nil {
+			log.Printf("ERROR: Failed to load latest snapshot: %v", err)
+		}
+
+		go c.StartSnapshotLoop(backgroundCtx, *snapshotInterval)
+	}
+
+	replicationCtx, stopReplication := context.WithCancel(backgroundCtx)
+	defer stopReplication()
+
+	var replicator *cache.KafkaReplicator
+	if *replicationBrokers != "" {
+		if *nodeID == "" {
+			log.Fatal("--node-id is required when --replication-brokers is set")
+		}
+
+		brokers := strings.Split(*replicationBrokers, ",")
+		replicator = cache.NewKafkaReplicator(brokers, *replicationTopic, "gocache-"+*nodeID, *nodeID)
+		replicator.SetPublishTimeout(*replicationPublishTimeout)
+
+		log.Println("Replaying replication log to catch up before accepting connections...")
+		if err := replicator.Bootstrap(replicationCtx, c.ApplyReplicated); err != nil {
+			log.Fatalf("Failed to bootstrap from replication log: %v", err)
+		}
+
+		c.UseReplicator(replicator, *nodeID)
+
+		go func() {
+			if err := replicator.Replay(replicationCtx, c.ApplyReplicated); err != nil {
+				log.Printf("Replication tailing stopped: %v", err)
+			}
+		}()
+	}
+
 	srv := server.New(c)
+
+	var clusterNode *cluster.Node
+	if *peersFlag != "" {
+		if *clusterID == 0 {
+			log.Fatal("--cluster-id is required when --peers is set")
+		}
+		if *clusterListenAddr == "" {
+			log.Fatal("--cluster-listen is required when --peers is set")
+		}
+
+		peers, err := parsePeers(*peersFlag)
+		if err != nil {
+			log.Fatalf("Invalid --peers: %v", err)
+		}
+
+		if *raftDir != "" {
+			if err := os.MkdirAll(*raftDir, 0o755); err != nil {
+				log.Fatalf("Failed to create --raft-dir: %v", err)
+			}
+		}
+
+		clusterNode, err = cluster.NewNode(cluster.Config{
+			ClusterID:  *clusterID,
+			ID:         *clusterID,
+			ListenAddr: *clusterListenAddr,
+			Peers:      peers,
+			RaftDir:    *raftDir,
+		}, c)
+		if err != nil {
+			log.Fatalf("Failed to start cluster node: %v", err)
+		}
+
+		go clusterNode.Run(backgroundCtx)
+
+		srv.UseCluster(clusterNode)
+	}
+
+	if *tlsCert != "" || *tlsKey != "" {
+		tlsConfig, err := loadTLSConfig(*tlsCert, *tlsKey, *tlsClientCA)
+		if err != nil {
+			log.Fatalf("Failed to load TLS configuration: %v", err)
+		}
+		srv.UseTLS(tlsConfig)
+	}
+
+	var fileAuth *auth.FileAuthenticator
+	var kafkaAudit *auth.KafkaAuditLogger
+	if *usersFile != "" {
+		fileAuth, err = auth.NewFileAuthenticator(*usersFile)
+		if err != nil {
+			log.Fatalf("Failed to load --users-file: %v", err)
+		}
+		srv.UseAuth(fileAuth)
+
+		switch *auditSink {
+		case "stderr":
+			srv.UseAudit(auth.StderrAuditLogger{})
+		case "kafka":
+			if *auditKafkaBrokers == "" {
+				log.Fatal("--audit-kafka-brokers is required when --audit-sink=kafka")
+			}
+			kafkaAudit = auth.NewKafkaAuditLogger(strings.Split(*auditKafkaBrokers, ","), *auditKafkaTopic)
+			srv.UseAudit(kafkaAudit)
+		default:
+			log.Fatalf("Unknown --audit-sink %q (want \"stderr\" or \"kafka\")", *auditSink)
+		}
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := fileAuth.Reload(); err != nil {
+					log.Printf("ERROR: Failed to reload --users-file: %v", err)
+				} else {
+					log.Println("Reloaded users file.")
+				}
+			}
+		}()
+	}
+
 	if err := srv.Listen(":" + *port); err != nil {
 		log.Fatalf("Failed to listen on port %v: %v", port, err)
 	}
@@ -68,5 +289,16 @@ func main() {
 
 	srv.Stop()
 	c.Stop()
+	stopBackground()
+	if replicator != nil {
+		if err := replicator.Close(); err != nil {
+			log.Printf("ERROR: Failed to close replicator: %v", err)
+		}
+	}
+	if kafkaAudit != nil {
+		if err := kafkaAudit.Close(); err != nil {
+			log.Printf("ERROR: Failed to close audit logger: %v", err)
+		}
+	}
 	log.Println("Server gracefully stopped.")
 }
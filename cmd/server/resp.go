@@ -0,0 +1,473 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"gocache/internal/cache"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// handleRESPConnection serves a connection that opened with a RESP array
+// (i.e. a real Redis client), parsing and replying in RESP2 wire format
+// until the connection closes. Pipelined commands are handled naturally
+// since each iteration only consumes exactly one array off the reader.
+func (s *Server) handleRESPConnection(conn io.Writer, reader *bufio.Reader, remoteAddr string) {
+	state := &connState{}
+
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		command := strings.ToUpper(args[0])
+		if command == "QUIT" {
+			writeSimpleString(conn, "OK")
+			return
+		}
+
+		if command == "AUTH" {
+			if _, err := s.authenticate(state, remoteAddr, args[1:]); err != nil {
+				writeError(conn, err.Error())
+			} else {
+				writeSimpleString(conn, "OK")
+			}
+			continue
+		}
+
+		if command == "HELLO" {
+			s.respHello(conn, state, remoteAddr, args[1:])
+			continue
+		}
+
+		if err := s.checkAccess(state, remoteAddr, command, args[1:]); err != nil {
+			writeError(conn, err.Error())
+			continue
+		}
+
+		s.dispatchRESP(conn, command, args[1:])
+	}
+}
+
+// respHello implements the RESP handshake: "HELLO [protover] [AUTH user
+// pass]". It exists so a client can authenticate before issuing any other
+// command, mirroring Redis 6's HELLO.
+func (s *Server) respHello(conn io.Writer, state *connState, remoteAddr string, args []string) {
+	for i := 0; i < len(args); i++ {
+		if strings.ToUpper(args[i]) == "AUTH" {
+			if i+2 >= len(args) {
+				writeError(conn, "syntax error in HELLO AUTH clause")
+				return
+			}
+			if _, err := s.authenticate(state, remoteAddr, args[i+1:i+3]); err != nil {
+				writeError(conn, err.Error())
+				return
+			}
+			i += 2
+		}
+	}
+
+	writeSimpleString(conn, "OK")
+}
+
+// maxRESPArrayLen and maxRESPBulkLen cap the array count and bulk-string
+// length readRESPCommand will believe before it's read a single byte of
+// payload, so a connection can't make it allocate gigabytes (or more than
+// fits in memory) just by sending a huge length prefix. Real commands and
+// values never get close to either limit; these exist purely to bound an
+// unauthenticated peer's request before AUTH has even run.
+const (
+	maxRESPArrayLen = 1024
+	maxRESPBulkLen  = 8 * 1024 * 1024
+)
+
+// readRESPCommand reads one RESP array of bulk strings, e.g.
+// "*2\r\n$3\r\nGET\r\n$1\r\nA\r\n".
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("resp: expected array, got %q", line)
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("resp: invalid array length %q", line)
+	}
+	if count > maxRESPArrayLen {
+		return nil, fmt.Errorf("resp: array length %d exceeds the %d-element limit", count, maxRESPArrayLen)
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := readRESPLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if len(header) == 0 || header[0] != '$' {
+			return nil, fmt.Errorf("resp: expected bulk string, got %q", header)
+		}
+
+		length, err := strconv.Atoi(header[1:])
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("resp: invalid bulk length %q", header)
+		}
+		if length > maxRESPBulkLen {
+			return nil, fmt.Errorf("resp: bulk length %d exceeds the %d-byte limit", length, maxRESPBulkLen)
+		}
+
+		buf := make([]byte, length+2) // +2 for the trailing \r\n
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:length]))
+	}
+
+	return args, nil
+}
+
+func readRESPLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeSimpleString(w io.Writer, s string) {
+	fmt.Fprintf(w, "+%s\r\n", s)
+}
+
+func writeError(w io.Writer, s string) {
+	fmt.Fprintf(w, "-ERR %s\r\n", s)
+}
+
+func writeInteger(w io.Writer, n int64) {
+	fmt.Fprintf(w, ":%d\r\n", n)
+}
+
+func writeBulkString(w io.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+func writeNilBulk(w io.Writer) {
+	io.WriteString(w, "$-1\r\n")
+}
+
+func writeArrayHeader(w io.Writer, n int) {
+	fmt.Fprintf(w, "*%d\r\n", n)
+}
+
+// dispatchRESP runs one command against s.cache and writes a typed RESP
+// reply. It mirrors the line-protocol switch in handleConnection, just
+// with Redis-shaped replies instead of newline-terminated text.
+func (s *Server) dispatchRESP(conn io.Writer, command string, args []string) {
+	switch command {
+	case "PING":
+		if len(args) == 0 {
+			writeSimpleString(conn, "PONG")
+		} else {
+			writeBulkString(conn, args[0])
+		}
+
+	case "SELECT", "CLIENT":
+		writeSimpleString(conn, "OK")
+
+	case "GET":
+		if len(args) != 1 {
+			writeError(conn, "wrong number of arguments for 'GET'")
+			return
+		}
+		value, err := s.cache.Get(args[0])
+		if err != nil {
+			writeNilBulk(conn)
+			return
+		}
+		writeBulkString(conn, fmt.Sprintf("%v", value))
+
+	case "MGET":
+		if len(args) == 0 {
+			writeError(conn, "wrong number of arguments for 'MGET'")
+			return
+		}
+		writeArrayHeader(conn, len(args))
+		for _, key := range args {
+			value, err := s.cache.Get(key)
+			if err != nil {
+				writeNilBulk(conn)
+			} else {
+				writeBulkString(conn, fmt.Sprintf("%v", value))
+			}
+		}
+
+	case "SET":
+		respSet(s, conn, args)
+
+	case "MSET":
+		if len(args) == 0 || len(args)%2 != 0 {
+			writeError(conn, "wrong number of arguments for 'MSET'")
+			return
+		}
+		for i := 0; i < len(args); i += 2 {
+			if err := s.executeSet(args[i], parseRESPValue(args[i+1]), 0); err != nil {
+				writeError(conn, err.Error())
+				return
+			}
+		}
+		writeSimpleString(conn, "OK")
+
+	case "DEL":
+		if len(args) == 0 {
+			writeError(conn, "wrong number of arguments for 'DEL'")
+			return
+		}
+		var deleted int64
+		for _, key := range args {
+			if _, err := s.cache.Get(key); err == nil {
+				deleted++
+			}
+			s.executeDelete(key)
+		}
+		writeInteger(conn, deleted)
+
+	case "EXISTS":
+		if len(args) == 0 {
+			writeError(conn, "wrong number of arguments for 'EXISTS'")
+			return
+		}
+		var count int64
+		for _, key := range args {
+			if _, err := s.cache.Get(key); err == nil {
+				count++
+			}
+		}
+		writeInteger(conn, count)
+
+	case "INCR":
+		if len(args) != 1 {
+			writeError(conn, "wrong number of arguments for 'INCR'")
+			return
+		}
+		newValue, err := s.executeIncr(args[0], 1)
+		if err != nil {
+			writeError(conn, err.Error())
+			return
+		}
+		writeInteger(conn, newValue)
+
+	case "DECR":
+		if len(args) != 1 {
+			writeError(conn, "wrong number of arguments for 'DECR'")
+			return
+		}
+		newValue, err := s.executeIncr(args[0], -1)
+		if err != nil {
+			writeError(conn, err.Error())
+			return
+		}
+		writeInteger(conn, newValue)
+
+	case "TTL":
+		if len(args) != 1 {
+			writeError(conn, "wrong number of arguments for 'TTL'")
+			return
+		}
+		ttl, err := s.cache.TTL(args[0])
+		if errors.Is(err, cache.ErrNotFound) {
+			writeInteger(conn, -2)
+			return
+		}
+		if ttl == 0 {
+			writeInteger(conn, -1)
+			return
+		}
+		writeInteger(conn, int64(ttl/time.Second))
+
+	case "EXPIRE":
+		if len(args) != 2 {
+			writeError(conn, "wrong number of arguments for 'EXPIRE'")
+			return
+		}
+		seconds, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			writeError(conn, "value is not an integer or out of range")
+			return
+		}
+		if err := s.cache.Expire(args[0], time.Duration(seconds)*time.Second); err != nil {
+			writeInteger(conn, 0)
+			return
+		}
+		writeInteger(conn, 1)
+
+	case "INFO":
+		var sb strings.Builder
+		for k, v := range s.cache.Info() {
+			sb.WriteString(fmt.Sprintf("%s:%s\r\n", k, v))
+		}
+		writeBulkString(conn, sb.String())
+
+	case "CONFIG":
+		if len(args) < 1 {
+			writeError(conn, "wrong number of arguments for 'CONFIG'")
+			return
+		}
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if len(args) != 2 {
+				writeError(conn, "wrong number of arguments for 'CONFIG GET'")
+				return
+			}
+			config := s.cache.GetConfig()
+			value, ok := config[args[1]]
+			if !ok {
+				writeArrayHeader(conn, 0)
+				return
+			}
+			writeArrayHeader(conn, 2)
+			writeBulkString(conn, args[1])
+			writeBulkString(conn, value)
+		case "SET":
+			if len(args) != 3 {
+				writeError(conn, "wrong number of arguments for 'CONFIG SET'")
+				return
+			}
+			if err := s.cache.SetConfig(args[1], args[2]); err != nil {
+				writeError(conn, err.Error())
+				return
+			}
+			writeSimpleString(conn, "OK")
+		default:
+			writeError(conn, fmt.Sprintf("unknown CONFIG subcommand '%s'", args[0]))
+		}
+
+	case "CLUSTER":
+		if len(args) < 1 {
+			writeError(conn, "wrong number of arguments for 'CLUSTER'")
+			return
+		}
+		var buf strings.Builder
+		s.handleClusterCommand(&buf, strings.ToUpper(args[0]), args[1:])
+		writeRESPFromPlainReply(conn, buf.String())
+
+	case "CONSISTENCY":
+		if len(args) != 1 || strings.ToUpper(args[0]) != "LINEAR" {
+			writeError(conn, "usage: CONSISTENCY LINEAR")
+			return
+		}
+		if s.cluster == nil {
+			writeError(conn, "clustering is not enabled on this node")
+			return
+		}
+		if err := s.cluster.ReadIndexLinear(context.Background()); err != nil {
+			writeError(conn, err.Error())
+			return
+		}
+		writeSimpleString(conn, "OK")
+
+	default:
+		writeError(conn, fmt.Sprintf("unknown command '%s'", command))
+	}
+}
+
+// writeRESPFromPlainReply adapts handleClusterCommand's line-protocol-style
+// "OK\n" / "ERROR: ...\n" reply to a RESP reply, so CLUSTER can share one
+// implementation across both protocols.
+func writeRESPFromPlainReply(conn io.Writer, reply string) {
+	reply = strings.TrimRight(reply, "\n")
+	if strings.HasPrefix(reply, "ERROR: ") {
+		writeError(conn, strings.TrimPrefix(reply, "ERROR: "))
+		return
+	}
+	writeSimpleString(conn, reply)
+}
+
+// respSet implements SET key value [EX seconds | PX milliseconds] [NX | XX].
+func respSet(s *Server, conn io.Writer, args []string) {
+	if len(args) < 2 {
+		writeError(conn, "wrong number of arguments for 'SET'")
+		return
+	}
+	key, valueStr := args[0], args[1]
+
+	var ttl time.Duration
+	var nx, xx bool
+
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "EX":
+			if i+1 >= len(args) {
+				writeError(conn, "syntax error")
+				return
+			}
+			i++
+			seconds, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil {
+				writeError(conn, "value is not an integer or out of range")
+				return
+			}
+			ttl = time.Duration(seconds) * time.Second
+		case "PX":
+			if i+1 >= len(args) {
+				writeError(conn, "syntax error")
+				return
+			}
+			i++
+			millis, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil {
+				writeError(conn, "value is not an integer or out of range")
+				return
+			}
+			ttl = time.Duration(millis) * time.Millisecond
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		default:
+			writeError(conn, "syntax error")
+			return
+		}
+	}
+
+	if nx || xx {
+		cond := cache.CondNX
+		if xx {
+			cond = cache.CondXX
+		}
+
+		if err := s.executeSetConditional(key, parseRESPValue(valueStr), ttl, cond); err != nil {
+			if errors.Is(err, cache.ErrConditionFailed) {
+				writeNilBulk(conn)
+				return
+			}
+			writeError(conn, err.Error())
+			return
+		}
+		writeSimpleString(conn, "OK")
+		return
+	}
+
+	if err := s.executeSet(key, parseRESPValue(valueStr), ttl); err != nil {
+		writeError(conn, err.Error())
+		return
+	}
+	writeSimpleString(conn, "OK")
+}
+
+// parseRESPValue mirrors the line protocol's SET: bulk strings that look
+// like integers are stored as int64 so INCR/DECR keep working on them.
+func parseRESPValue(s string) interface{} {
+	if intValue, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return intValue
+	}
+	return s
+}
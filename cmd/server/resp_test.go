@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"gocache/internal/cache"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReadRESPCommand_ParsesArgs(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$1\r\nA\r\n"))
+
+	args, err := readRESPCommand(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"GET", "A"}
+	if len(args) != len(want) {
+		t.Fatalf("got %d args, want %d: %v", len(args), len(want), args)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Fatalf("arg %d = %q, want %q", i, args[i], want[i])
+		}
+	}
+}
+
+func TestReadRESPCommand_RejectsOversizedArray(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("*" + strconv.Itoa(maxRESPArrayLen+1) + "\r\n"))
+
+	if _, err := readRESPCommand(reader); err == nil {
+		t.Fatal("expected an error for an array length past the limit, got nil")
+	}
+}
+
+func TestReadRESPCommand_RejectsOversizedBulk(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("*1\r\n$" + strconv.Itoa(maxRESPBulkLen+1) + "\r\n"))
+
+	if _, err := readRESPCommand(reader); err == nil {
+		t.Fatal("expected an error for a bulk length past the limit, got nil")
+	}
+}
+
+func TestRespSet_NXOnlySucceedsOnce(t *testing.T) {
+	s := New(cache.NewShardedCache(1, 0, 0, 0))
+
+	var buf bytes.Buffer
+	s.dispatchRESP(&buf, "SET", []string{"A", "v1", "NX"})
+	if got := buf.String(); got != "+OK\r\n" {
+		t.Fatalf("first SET NX: got %q, want +OK", got)
+	}
+
+	buf.Reset()
+	s.dispatchRESP(&buf, "SET", []string{"A", "v2", "NX"})
+	if got := buf.String(); got != "$-1\r\n" {
+		t.Fatalf("second SET NX on an existing key: got %q, want a nil bulk reply", got)
+	}
+
+	value, err := s.cache.Get("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.(string) != "v1" {
+		t.Fatalf("got %v, want v1: SET NX should not have overwritten the key", value)
+	}
+}
+
+func TestRespSet_NXRaceIsAtomic(t *testing.T) {
+	// Many goroutines race "SET A v NX" against the same absent key over
+	// the full dispatch path (not just the cache primitive); exactly one
+	// may see +OK.
+	s := New(cache.NewShardedCache(1, 0, 0, 0))
+
+	const racers = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var okCount int
+
+	wg.Add(racers)
+	for i := 0; i < racers; i++ {
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			s.dispatchRESP(&buf, "SET", []string{"A", "v", "NX"})
+			if buf.String() == "+OK\r\n" {
+				mu.Lock()
+				okCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if okCount != 1 {
+		t.Fatalf("expected exactly 1 SET NX to succeed, got %d", okCount)
+	}
+}
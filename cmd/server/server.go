@@ -2,9 +2,13 @@ package server
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"gocache/internal/auth"
 	"gocache/internal/cache"
+	"gocache/internal/cluster"
 	"io"
 	"log"
 	"net"
@@ -18,6 +22,18 @@ type Server struct {
 	cache    cache.Cache
 	listener net.Listener
 	wg       sync.WaitGroup
+
+	cluster *cluster.Node
+
+	tlsConfig *tls.Config
+	auth      auth.Authenticator
+	audit     auth.AuditLogger
+}
+
+// connState is one connection's mutable session state; a single goroutine
+// owns it for the lifetime of the connection, so it needs no locking.
+type connState struct {
+	user *auth.User
 }
 
 func New(c cache.Cache) *Server {
@@ -26,12 +42,40 @@ func New(c cache.Cache) *Server {
 	}
 }
 
+// UseCluster enables the CLUSTER and CONSISTENCY commands, backing them
+// with node's raft group.
+func (s *Server) UseCluster(node *cluster.Node) {
+	s.cluster = node
+}
+
+// UseTLS makes Listen wrap the raw TCP listener with cfg, requiring every
+// client to speak TLS.
+func (s *Server) UseTLS(cfg *tls.Config) {
+	s.tlsConfig = cfg
+}
+
+// UseAuth requires clients to AUTH with a.Authenticate before running any
+// other command; without it the server accepts every connection
+// unauthenticated, as before.
+func (s *Server) UseAuth(a auth.Authenticator) {
+	s.auth = a
+}
+
+// UseAudit records every authn/authz decision UseAuth makes to logger.
+func (s *Server) UseAudit(logger auth.AuditLogger) {
+	s.audit = logger
+}
+
 func (s *Server) Listen(addr string) error {
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
 
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+
 	s.listener = listener
 
 	return nil
@@ -57,7 +101,22 @@ func (s *Server) handleConnection(conn net.Conn) {
 	defer s.wg.Done()
 	defer conn.Close()
 
-	scanner := bufio.NewScanner(conn)
+	remoteAddr := conn.RemoteAddr().String()
+	state := &connState{}
+
+	reader := bufio.NewReader(conn)
+
+	firstByte, err := reader.Peek(1)
+	if err != nil {
+		return
+	}
+
+	if firstByte[0] == '*' {
+		s.handleRESPConnection(conn, reader, remoteAddr)
+		return
+	}
+
+	scanner := bufio.NewScanner(reader)
 
 ConnectionLoop:
 	for scanner.Scan() {
@@ -68,6 +127,20 @@ ConnectionLoop:
 
 		command := strings.ToUpper(parts[0])
 
+		if command == "AUTH" {
+			if _, err := s.authenticate(state, remoteAddr, parts[1:]); err != nil {
+				fmt.Fprintf(conn, "ERROR: %v\n", err)
+			} else {
+				io.WriteString(conn, "OK\n")
+			}
+			continue
+		}
+
+		if err := s.checkAccess(state, remoteAddr, command, parts[1:]); err != nil {
+			fmt.Fprintf(conn, "ERROR: %v\n", err)
+			continue
+		}
+
 		switch command {
 		case "GET":
 			if len(parts) != 2 {
@@ -109,7 +182,7 @@ ConnectionLoop:
 			}
 
 			// Передаем в кэш значение правильного типа
-			if err := s.cache.Set(key, valueToStore, ttl); err != nil {
+			if err := s.executeSet(key, valueToStore, ttl); err != nil {
 				fmt.Fprintf(conn, "ERROR: %v\n", err)
 			} else {
 				io.WriteString(conn, "OK\n")
@@ -122,7 +195,7 @@ ConnectionLoop:
 			key := parts[1]
 			// Метод Delete ничего не возвращает, поэтому сначала проверяем наличие
 			_, err := s.cache.Get(key)
-			s.cache.Delete(key)
+			s.executeDelete(key)
 			if err != nil {
 				io.WriteString(conn, "0\n") // Не было ключа
 			} else {
@@ -135,7 +208,7 @@ ConnectionLoop:
 				continue
 			}
 			key := parts[1]
-			newValue, err := s.cache.Incr(key)
+			newValue, err := s.executeIncr(key, 1)
 			if err != nil {
 				fmt.Fprintf(conn, "ERROR: %v\n", err)
 			} else {
@@ -148,7 +221,7 @@ ConnectionLoop:
 				continue // <-- БЫЛА ОШИБКА
 			}
 			key := parts[1]
-			newValue, err := s.cache.Decr(key)
+			newValue, err := s.executeIncr(key, -1)
 			if err != nil {
 				fmt.Fprintf(conn, "ERROR: %v\n", err)
 			} else {
@@ -212,6 +285,28 @@ ConnectionLoop:
 			default:
 				fmt.Fprintf(conn, "ERROR: unknown command '%s'\n", command)
 			}
+
+		case "CLUSTER":
+			if len(parts) < 2 {
+				fmt.Fprintf(conn, "ERROR: wrong number of arguments for 'CLUSTER'\n")
+				continue
+			}
+			s.handleClusterCommand(conn, strings.ToUpper(parts[1]), parts[2:])
+
+		case "CONSISTENCY":
+			if len(parts) != 2 || strings.ToUpper(parts[1]) != "LINEAR" {
+				fmt.Fprintf(conn, "ERROR: usage: CONSISTENCY LINEAR\n")
+				continue
+			}
+			if s.cluster == nil {
+				fmt.Fprintf(conn, "ERROR: clustering is not enabled on this node\n")
+				continue
+			}
+			if err := s.cluster.ReadIndexLinear(context.Background()); err != nil {
+				fmt.Fprintf(conn, "ERROR: %v\n", err)
+				continue
+			}
+			io.WriteString(conn, "OK\n")
 		}
 
 		if err := scanner.Err(); err != nil {
@@ -228,3 +323,238 @@ func (s *Server) Stop() {
 	s.wg.Wait()
 	log.Println("All connections closed.")
 }
+
+// executeSet applies a SET either through the raft log, when clustering is
+// enabled, so every node's FSM agrees on the value before the client sees
+// OK, or directly against the local cache otherwise.
+func (s *Server) executeSet(key string, value interface{}, ttl time.Duration) error {
+	if s.cluster == nil {
+		return s.cache.Set(key, value, ttl)
+	}
+
+	op, err := s.cache.NewSetOperation(key, value, ttl)
+	if err != nil {
+		return err
+	}
+	return s.cluster.Propose(context.Background(), op)
+}
+
+// executeSetConditional is executeSet's NX/XX counterpart: the existence
+// check and the write happen atomically, either locally under the
+// shard's own mutex or, under cluster mode, at raft apply time - never
+// as a separate Get followed by a Set.
+func (s *Server) executeSetConditional(key string, value interface{}, ttl time.Duration, cond cache.SetCondition) error {
+	if s.cluster == nil {
+		return s.cache.SetConditional(key, value, ttl, cond)
+	}
+
+	op, err := s.cache.NewConditionalSetOperation(key, value, ttl, cond)
+	if err != nil {
+		return err
+	}
+	return s.cluster.Propose(context.Background(), op)
+}
+
+// executeDelete is executeSet's DELETE/DEL counterpart.
+func (s *Server) executeDelete(key string) error {
+	if s.cluster == nil {
+		return s.cache.Delete(key)
+	}
+	return s.cluster.Propose(context.Background(), s.cache.NewDeleteOperation(key))
+}
+
+// executeIncr is executeSet's INCR/DECR counterpart (delta is negative for
+// a decrement). Under cluster mode the raft commit applies the delta via
+// ApplyReplicated before Propose returns, so the resulting value is read
+// back locally rather than returned by Propose itself.
+func (s *Server) executeIncr(key string, delta int64) (int64, error) {
+	if s.cluster == nil {
+		if delta < 0 {
+			return s.cache.Decr(key)
+		}
+		return s.cache.Incr(key)
+	}
+
+	if err := s.cluster.Propose(context.Background(), s.cache.NewIncrOperation(key, delta)); err != nil {
+		return 0, err
+	}
+
+	value, err := s.cache.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	newValue, ok := value.(int64)
+	if !ok {
+		return 0, errors.New("value is not an integer")
+	}
+	return newValue, nil
+}
+
+// handleClusterCommand runs CLUSTER ADD <id> <host:port> or CLUSTER
+// REMOVE <id> against the raft group and writes a plain-text reply. It's
+// shared by the line protocol and dispatchRESP so both surfaces offer the
+// same membership commands.
+func (s *Server) handleClusterCommand(conn io.Writer, subcommand string, args []string) {
+	if s.cluster == nil {
+		fmt.Fprintf(conn, "ERROR: clustering is not enabled on this node\n")
+		return
+	}
+
+	switch subcommand {
+	case "ADD":
+		if len(args) != 2 {
+			fmt.Fprintf(conn, "ERROR: usage: CLUSTER ADD <id> <host:port>\n")
+			return
+		}
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(conn, "ERROR: node id must be an integer\n")
+			return
+		}
+		if err := s.cluster.AddMember(context.Background(), id, args[1]); err != nil {
+			fmt.Fprintf(conn, "ERROR: %v\n", err)
+			return
+		}
+		io.WriteString(conn, "OK\n")
+
+	case "REMOVE":
+		if len(args) != 1 {
+			fmt.Fprintf(conn, "ERROR: usage: CLUSTER REMOVE <id>\n")
+			return
+		}
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(conn, "ERROR: node id must be an integer\n")
+			return
+		}
+		if err := s.cluster.RemoveMember(context.Background(), id); err != nil {
+			fmt.Fprintf(conn, "ERROR: %v\n", err)
+			return
+		}
+		io.WriteString(conn, "OK\n")
+
+	default:
+		fmt.Fprintf(conn, "ERROR: unknown CLUSTER subcommand '%s'\n", subcommand)
+	}
+}
+
+// authenticate runs AUTH <user> <password> against s.auth and, on success,
+// binds the resulting user to state for the rest of the connection.
+func (s *Server) authenticate(state *connState, remoteAddr string, args []string) (*auth.User, error) {
+	if s.auth == nil {
+		return nil, errors.New("authentication is not enabled on this node")
+	}
+	if len(args) != 2 {
+		return nil, errors.New("wrong number of arguments for 'AUTH'")
+	}
+
+	user, err := s.auth.Authenticate(args[0], args[1])
+	s.auditLog(remoteAddr, args[0], "AUTH", "", err == nil, errString(err))
+	if err != nil {
+		return nil, err
+	}
+
+	state.user = user
+	return user, nil
+}
+
+// checkAccess is a no-op when auth isn't configured; otherwise it enforces
+// that state's connection has authenticated, that its user may run
+// command, and (for commands that target one) that it may touch key. It's
+// called once per incoming command, before dispatch, by both protocol
+// loops. AUTH and HELLO are handled separately so a client can always
+// authenticate in the first place.
+func (s *Server) checkAccess(state *connState, remoteAddr, command string, args []string) error {
+	if s.auth == nil {
+		return nil
+	}
+	if command == "AUTH" || command == "HELLO" {
+		return nil
+	}
+
+	if state.user == nil {
+		err := errors.New("NOAUTH Authentication required")
+		s.auditLog(remoteAddr, "", command, commandKey(command, args), false, err.Error())
+		return err
+	}
+
+	requiresAdmin := command == "INFO" || (command == "CONFIG" && len(args) > 0 && strings.ToUpper(args[0]) == "SET")
+	if requiresAdmin && !state.user.Admin {
+		err := errors.New("NOPERM this command requires the admin role")
+		s.auditLog(remoteAddr, state.user.Name, command, "", false, err.Error())
+		return err
+	}
+
+	if !state.user.AllowsCommand(command) {
+		err := errors.New("NOPERM this user has no permissions to run this command")
+		s.auditLog(remoteAddr, state.user.Name, command, "", false, err.Error())
+		return err
+	}
+
+	keys := commandKeys(command, args)
+	for _, key := range keys {
+		if !state.user.AllowsKey(key) {
+			err := fmt.Errorf("NOPERM this user has no permissions to access key %q", key)
+			s.auditLog(remoteAddr, state.user.Name, command, key, false, err.Error())
+			return err
+		}
+	}
+
+	s.auditLog(remoteAddr, state.user.Name, command, commandKey(command, args), true, "")
+	return nil
+}
+
+// commandKey returns the key a single-key command targets, the first key
+// of a multi-key command (audit logging reports those under their first
+// key for brevity), or "" for commands that don't target a key.
+func commandKey(command string, args []string) string {
+	keys := commandKeys(command, args)
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
+
+// commandKeys returns every key command touches. For MSET/DEL/MGET that's
+// every key in the batch, not just the first - a user whose KeyPatterns
+// restrict them to e.g. "foo:*" must be denied if any key in the batch
+// falls outside it, not just the leading one.
+func commandKeys(command string, args []string) []string {
+	switch command {
+	case "GET", "SET", "DELETE", "INCR", "DECR", "TTL", "EXPIRE":
+		if len(args) > 0 {
+			return args[:1]
+		}
+	case "DEL", "MGET":
+		return args
+	case "MSET":
+		keys := make([]string, 0, (len(args)+1)/2)
+		for i := 0; i+1 < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+		return keys
+	}
+	return nil
+}
+
+func (s *Server) auditLog(remoteAddr, user, command, key string, allowed bool, reason string) {
+	if s.audit == nil {
+		return
+	}
+	s.audit.Log(auth.AuditEntry{
+		Time:       time.Now(),
+		RemoteAddr: remoteAddr,
+		User:       user,
+		Command:    command,
+		Key:        key,
+		Allowed:    allowed,
+		Reason:     reason,
+	})
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
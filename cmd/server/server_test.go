@@ -0,0 +1,28 @@
+package server
+
+import "testing"
+
+func TestCommandKeys_MultiKeyCommandsCoverEveryKey(t *testing.T) {
+	cases := []struct {
+		command string
+		args    []string
+		want    []string
+	}{
+		{"GET", []string{"foo"}, []string{"foo"}},
+		{"DEL", []string{"foo:1", "bar:secret"}, []string{"foo:1", "bar:secret"}},
+		{"MGET", []string{"foo:1", "bar:secret"}, []string{"foo:1", "bar:secret"}},
+		{"MSET", []string{"foo:1", "v", "bar:secret", "evil"}, []string{"foo:1", "bar:secret"}},
+	}
+
+	for _, c := range cases {
+		got := commandKeys(c.command, c.args)
+		if len(got) != len(c.want) {
+			t.Fatalf("%s %v: got %v, want %v", c.command, c.args, got, c.want)
+		}
+		for i := range c.want {
+			if got[i] != c.want[i] {
+				t.Fatalf("%s %v: got %v, want %v", c.command, c.args, got, c.want)
+			}
+		}
+	}
+}
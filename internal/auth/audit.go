@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// AuditEntry records one authentication or authorization decision.
+type AuditEntry struct {
+	Time       time.Time
+	RemoteAddr string
+	User       string // empty if authentication hadn't succeeded yet
+	Command    string
+	Key        string // first key for multi-key commands; empty for commands that don't target a key
+	Allowed    bool
+	Reason     string // why a denial happened; empty on success
+}
+
+// AuditLogger persists AuditEntry values to a sink. Log must not block the
+// connection goroutine for long; implementations that talk to a remote
+// service should buffer/drop rather than stall command dispatch.
+type AuditLogger interface {
+	Log(entry AuditEntry)
+}
+
+// StderrAuditLogger writes one log line per entry via the standard logger,
+// consistent with the rest of the server's logging.
+type StderrAuditLogger struct{}
+
+// Log implements AuditLogger.
+func (StderrAuditLogger) Log(entry AuditEntry) {
+	status := "ALLOW"
+	if !entry.Allowed {
+		status = "DENY"
+	}
+
+	log.Printf("audit: %s user=%q addr=%s command=%s key=%q%s",
+		status, entry.User, entry.RemoteAddr, entry.Command, entry.Key, reasonSuffix(entry.Reason))
+}
+
+func reasonSuffix(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return fmt.Sprintf(" reason=%q", reason)
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// auditBufferSize bounds how many entries Log can get ahead of the
+// background writer before it starts dropping them.
+const auditBufferSize = 1024
+
+// auditWriteTimeout bounds how long a single entry's Kafka write may take.
+const auditWriteTimeout = 5 * time.Second
+
+// KafkaAuditLogger writes audit entries to a Kafka topic, reusing the same
+// segmentio/kafka-go dependency the replication log is built on. Log only
+// enqueues the entry; a single background goroutine does the actual
+// writing, so a slow or unreachable broker never blocks the connection
+// goroutine that called Log, per AuditLogger's contract.
+type KafkaAuditLogger struct {
+	writer  *kafka.Writer
+	entries chan AuditEntry
+	done    chan struct{}
+}
+
+// NewKafkaAuditLogger dials brokers, prepares a writer for topic, and
+// starts the background goroutine that drains entries to it.
+func NewKafkaAuditLogger(brokers []string, topic string) *KafkaAuditLogger {
+	k := &KafkaAuditLogger{
+		writer: &kafka.Writer{
+			Addr:  kafka.TCP(brokers...),
+			Topic: topic,
+		},
+		entries: make(chan AuditEntry, auditBufferSize),
+		done:    make(chan struct{}),
+	}
+	go k.run()
+	return k
+}
+
+// Log implements AuditLogger by enqueueing entry for the background
+// writer. If the buffer is full - the broker can't keep up or is down -
+// the entry is dropped rather than blocking the caller.
+func (k *KafkaAuditLogger) Log(entry AuditEntry) {
+	select {
+	case k.entries <- entry:
+	default:
+		log.Printf("audit: buffer full, dropping entry for user %q", entry.User)
+	}
+}
+
+func (k *KafkaAuditLogger) run() {
+	defer close(k.done)
+	for entry := range k.entries {
+		k.write(entry)
+	}
+}
+
+func (k *KafkaAuditLogger) write(entry AuditEntry) {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("audit: encoding entry: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+	defer cancel()
+
+	if err := k.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(entry.User),
+		Value: payload,
+	}); err != nil {
+		log.Printf("audit: writing to kafka: %v", err)
+	}
+}
+
+// Close drains any entries still buffered, then flushes and closes the
+// underlying writer.
+func (k *KafkaAuditLogger) Close() error {
+	close(k.entries)
+	<-k.done
+	return k.writer.Close()
+}
@@ -0,0 +1,64 @@
+// Package auth provides authentication and per-key authorization for the
+// server, plus an audit trail of the decisions it makes.
+package auth
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// ErrInvalidCredentials is returned by Authenticator.Authenticate when the
+// username doesn't exist or the password doesn't match.
+var ErrInvalidCredentials = errors.New("auth: invalid username or password")
+
+// User is an authenticated account's identity and permissions.
+type User struct {
+	Name  string
+	Admin bool
+	// AllowedCommands is the set of commands this user may run, matched
+	// case-insensitively against the command name. A nil/empty set means
+	// "every command".
+	AllowedCommands map[string]bool
+	// KeyPatterns restricts which keys this user may touch, matched with
+	// path.Match, where "*" matches any run of characters other than "/"
+	// (so "user:*" matches both "user:123" and "user:123:sessions").
+	// A nil/empty slice means "every key".
+	KeyPatterns []string
+}
+
+// AllowsCommand reports whether u may run command.
+func (u *User) AllowsCommand(command string) bool {
+	if len(u.AllowedCommands) == 0 {
+		return true
+	}
+	if u.AllowedCommands[command] {
+		return true
+	}
+	for allowed, ok := range u.AllowedCommands {
+		if ok && strings.EqualFold(allowed, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsKey reports whether u may touch key. key == "" (commands that don't
+// target a single key) is always allowed.
+func (u *User) AllowsKey(key string) bool {
+	if key == "" || len(u.KeyPatterns) == 0 {
+		return true
+	}
+	for _, pattern := range u.KeyPatterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator verifies a username/password pair and returns the User it
+// resolves to.
+type Authenticator interface {
+	Authenticate(username, password string) (*User, error)
+}
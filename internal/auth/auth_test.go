@@ -0,0 +1,41 @@
+package auth_test
+
+import (
+	"gocache/internal/auth"
+	"testing"
+)
+
+func TestUser_AllowsCommand(t *testing.T) {
+	u := &auth.User{AllowedCommands: map[string]bool{"GET": true, "SET": true}}
+
+	if !u.AllowsCommand("GET") {
+		t.Fatal("GET should be allowed")
+	}
+	if u.AllowsCommand("DELETE") {
+		t.Fatal("DELETE should not be allowed")
+	}
+
+	unrestricted := &auth.User{}
+	if !unrestricted.AllowsCommand("DELETE") {
+		t.Fatal("a user with no AllowedCommands should allow every command")
+	}
+}
+
+func TestUser_AllowsKey(t *testing.T) {
+	u := &auth.User{KeyPatterns: []string{"user:*"}}
+
+	if !u.AllowsKey("user:123") {
+		t.Fatal("user:123 should match user:*")
+	}
+	if u.AllowsKey("admin:123") {
+		t.Fatal("admin:123 should not match user:*")
+	}
+	if !u.AllowsKey("") {
+		t.Fatal("an empty key (commands with no single target) should always be allowed")
+	}
+
+	unrestricted := &auth.User{}
+	if !unrestricted.AllowsKey("anything") {
+		t.Fatal("a user with no KeyPatterns should allow every key")
+	}
+}
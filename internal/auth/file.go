@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// FileAuthenticator loads bcrypt-hashed credentials from a flat text file,
+// one user per line:
+//
+//	name:bcryptHash:role:cmd1,cmd2,...:pattern1,pattern2,...
+//
+// role is "admin" or "user"; the command and pattern lists may be empty,
+// meaning "all commands"/"all keys". Call Reload to pick up changes to the
+// file without restarting the server.
+type FileAuthenticator struct {
+	path string
+
+	mu    sync.RWMutex
+	users map[string]fileUser
+}
+
+type fileUser struct {
+	user         User
+	passwordHash []byte
+}
+
+// NewFileAuthenticator loads path and returns a ready-to-use Authenticator.
+func NewFileAuthenticator(path string) (*FileAuthenticator, error) {
+	a := &FileAuthenticator{path: path}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload re-reads the users file, swapping in the new credential set
+// atomically so a concurrent Authenticate never sees a half-applied
+// update. The server calls this on SIGHUP.
+func (a *FileAuthenticator) Reload() error {
+	file, err := os.Open(a.path)
+	if err != nil {
+		return fmt.Errorf("auth: opening users file: %w", err)
+	}
+	defer file.Close()
+
+	users := make(map[string]fileUser)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			return fmt.Errorf("auth: malformed users file line %q", line)
+		}
+
+		name, hash, role := fields[0], fields[1], fields[2]
+
+		var commands map[string]bool
+		if len(fields) > 3 && fields[3] != "" {
+			commands = make(map[string]bool)
+			for _, cmd := range strings.Split(fields[3], ",") {
+				commands[strings.ToUpper(cmd)] = true
+			}
+		}
+
+		var patterns []string
+		if len(fields) > 4 && fields[4] != "" {
+			patterns = strings.Split(fields[4], ",")
+		}
+
+		users[name] = fileUser{
+			user: User{
+				Name:            name,
+				Admin:           role == "admin",
+				AllowedCommands: commands,
+				KeyPatterns:     patterns,
+			},
+			passwordHash: []byte(hash),
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("auth: reading users file: %w", err)
+	}
+
+	a.mu.Lock()
+	a.users = users
+	a.mu.Unlock()
+
+	return nil
+}
+
+// Authenticate implements Authenticator.
+func (a *FileAuthenticator) Authenticate(username, password string) (*User, error) {
+	a.mu.RLock()
+	record, ok := a.users[username]
+	a.mu.RUnlock()
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword(record.passwordHash, []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	user := record.user
+	return &user, nil
+}
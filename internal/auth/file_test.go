@@ -0,0 +1,99 @@
+package auth_test
+
+import (
+	"fmt"
+	"gocache/internal/auth"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeUsersFile(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "users.txt")
+	content := ""
+	for _, line := range lines {
+		content += line + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func hashPassword(t *testing.T, password string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+func TestFileAuthenticator_AuthenticateAndReload(t *testing.T) {
+	hash := hashPassword(t, "hunter2")
+	path := writeUsersFile(t,
+		fmt.Sprintf("alice:%s:admin::", hash),
+		fmt.Sprintf("bob:%s:user:GET,SET:bob-*", hash),
+	)
+
+	a, err := auth.NewFileAuthenticator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin, err := a.Authenticate("alice", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !admin.Admin {
+		t.Fatal("alice should be an admin")
+	}
+	if !admin.AllowsCommand("DELETE") {
+		t.Fatal("alice has no AllowedCommands, so every command should be allowed")
+	}
+
+	bob, err := a.Authenticate("bob", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bob.Admin {
+		t.Fatal("bob should not be an admin")
+	}
+	if !bob.AllowsCommand("GET") || bob.AllowsCommand("DELETE") {
+		t.Fatal("bob should only be allowed GET/SET")
+	}
+	if !bob.AllowsKey("bob-42") || bob.AllowsKey("other-42") {
+		t.Fatal("bob should only be allowed bob-* keys")
+	}
+
+	if _, err := a.Authenticate("alice", "wrong-password"); err != auth.ErrInvalidCredentials {
+		t.Fatalf("got %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := a.Authenticate("nobody", "hunter2"); err != auth.ErrInvalidCredentials {
+		t.Fatalf("got %v, want ErrInvalidCredentials", err)
+	}
+
+	// Reload should pick up a removed user.
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("alice:%s:admin::\n", hash)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate("bob", "hunter2"); err != auth.ErrInvalidCredentials {
+		t.Fatal("bob should no longer exist after Reload")
+	}
+}
+
+func TestFileAuthenticator_MalformedLine(t *testing.T) {
+	path := writeUsersFile(t, "alice:onlytwofields")
+
+	if _, err := auth.NewFileAuthenticator(path); err == nil {
+		t.Fatal("expected an error for a line with fewer than 3 fields")
+	}
+}
@@ -1,49 +1,128 @@
 package cache
 
 import (
+	"bytes"
 	"container/list"
+	"encoding/binary"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"gocache/internal/snapshot"
 	"hash/fnv"
 	_ "hash/fnv"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 var ErrNotFound = errors.New("key not found")
 
+// ErrConditionFailed is returned by a conditional Set (SET ... NX/XX)
+// whose condition wasn't met. It isn't a failure the caller should
+// surface as an error - it mirrors Redis's nil reply for a skipped SET
+// NX/XX - so callers should check for it with errors.Is rather than
+// treating it like any other error.
+var ErrConditionFailed = errors.New("cache: set condition not met")
+
+// SetCondition constrains when a conditional Set takes effect. The zero
+// value, CondNone, always applies and is what every non-conditional Set
+// uses.
+type SetCondition uint8
+
+const (
+	CondNone SetCondition = iota
+	// CondNX applies the Set only if the key is currently absent (or
+	// expired), matching Redis's SET ... NX.
+	CondNX
+	// CondXX applies the Set only if the key is currently present and
+	// unexpired, matching Redis's SET ... XX.
+	CondXX
+)
+
 type Cache interface {
 	Get(key string) (interface{}, error)
 	Set(key string, value interface{}, ttl time.Duration) error
+	// SetConditional behaves like Set, but only takes effect if cond
+	// holds; the check and the write happen atomically, so two racing
+	// callers can't both observe a key absent and both write under
+	// CondNX. It returns ErrConditionFailed, not an ordinary error, when
+	// cond isn't met.
+	SetConditional(key string, value interface{}, ttl time.Duration, cond SetCondition) error
 	Delete(key string) error
 	SaveToFile(path string) error
 	LoadFromFile(path string) error
 	Incr(key string) (int64, error)
 	Decr(key string) (int64, error)
+	TTL(key string) (time.Duration, error)
+	Expire(key string, ttl time.Duration) error
+	Info() map[string]string
+	GetConfig() map[string]string
+	SetConfig(param string, value string) error
 	Stop()
+
+	// NewSetOperation, NewDeleteOperation and NewIncrOperation build the
+	// Operation a caller outside this package (namely cmd/server, when
+	// cluster mode is enabled) can hand to an external consensus log - e.g.
+	// cluster.Node.Propose - instead of mutating the cache directly. The
+	// log applies the Operation back via ApplyReplicated once it commits.
+	NewSetOperation(key string, value interface{}, ttl time.Duration) (Operation, error)
+	// NewConditionalSetOperation is NewSetOperation's SetConditional
+	// counterpart: the consensus log's apply step evaluates cond itself
+	// (see cacheShard.setConditionalNoPublish) rather than the proposer
+	// checking it up front, since only the apply order - not the
+	// proposal order - is guaranteed consistent across every node.
+	NewConditionalSetOperation(key string, value interface{}, ttl time.Duration, cond SetCondition) (Operation, error)
+	NewDeleteOperation(key string) Operation
+	NewIncrOperation(key string, delta int64) Operation
+}
+
+// SecondaryStore is an L2 backend that ShardedCache spills LRU-evicted
+// items into once the in-memory item-count or byte budget is exceeded. Implementations
+// only need to be keyed on the cache key; they own their own durability.
+type SecondaryStore interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte, expiration int64) error
+	Delete(key string) error
 }
 
 type cacheShard struct {
-	mu      *sync.RWMutex
-	items   map[string]*list.Element
-	ll      *list.List
-	maxSize int
-	stopCh  chan struct{}
+	mu         *sync.RWMutex
+	items      map[string]*list.Element
+	ll         *list.List
+	maxSize    int
+	maxBytes   int64
+	bytes      int64
+	secondary  SecondaryStore
+	replicator Replicator
+	nodeID     string
+	lsn        uint64
+	stopCh     chan struct{}
 }
 
 type ShardedCache struct {
 	shards     []*cacheShard
 	shardCount uint32
+
+	snapshotStore snapshot.SnapshotStore
+	snapshotter   snapshot.Snapshotter
 }
 
 type item struct {
 	Key        string
 	Value      interface{}
 	Expiration int64
+	Size       int64
 }
 
-func NewShardedCache(shardCount uint32, totalMaxSize int, cleanupInterval time.Duration) *ShardedCache {
+// NewShardedCache creates a cache with totalMaxSize items and totalMaxBytes
+// bytes of budget split evenly across shardCount shards. A zero
+// totalMaxBytes disables byte-based eviction; a zero totalMaxSize does not
+// disable count-based eviction but caps each shard at one item.
+func NewShardedCache(shardCount uint32, totalMaxSize int, totalMaxBytes int64, cleanupInterval time.Duration) *ShardedCache {
 	sc := &ShardedCache{
 		shards:     make([]*cacheShard, shardCount),
 		shardCount: shardCount,
@@ -57,13 +136,22 @@ func NewShardedCache(shardCount uint32, totalMaxSize int, cleanupInterval time.D
 		}
 	}
 
+	var shardMaxBytes int64
+	if totalMaxBytes > 0 {
+		shardMaxBytes = totalMaxBytes / int64(shardCount)
+		if shardMaxBytes < 1 {
+			shardMaxBytes = 1
+		}
+	}
+
 	for i := 0; i < int(shardCount); i++ {
 		sc.shards[i] = &cacheShard{
-			items:   make(map[string]*list.Element),
-			mu:      new(sync.RWMutex),
-			ll:      list.New(),
-			maxSize: shardMaxCount,
-			stopCh:  make(chan struct{}),
+			items:    make(map[string]*list.Element),
+			mu:       new(sync.RWMutex),
+			ll:       list.New(),
+			maxSize:  shardMaxCount,
+			maxBytes: shardMaxBytes,
+			stopCh:   make(chan struct{}),
 		}
 
 		if cleanupInterval > 0 {
@@ -74,6 +162,105 @@ func NewShardedCache(shardCount uint32, totalMaxSize int, cleanupInterval time.D
 	return sc
 }
 
+// UseSecondaryStore wires an L2 backend that LRU-evicted items spill into.
+// Get falls through to it on a shard miss and repopulates L1.
+func (sc *ShardedCache) UseSecondaryStore(s SecondaryStore) {
+	for _, shard := range sc.shards {
+		shard.mu.Lock()
+		shard.secondary = s
+		shard.mu.Unlock()
+	}
+}
+
+// UseReplicator wires a write-ahead log that every Set/Delete/Incr/Decr
+// publishes to, tagged with nodeID so replay can recognize and skip the
+// node's own writes.
+func (sc *ShardedCache) UseReplicator(r Replicator, nodeID string) {
+	for _, shard := range sc.shards {
+		shard.mu.Lock()
+		shard.replicator = r
+		shard.nodeID = nodeID
+		shard.mu.Unlock()
+	}
+}
+
+// ApplyReplicated applies an Operation received from the write-ahead log
+// without re-publishing it, so replicating nodes don't echo writes back at
+// each other.
+func (sc *ShardedCache) ApplyReplicated(op Operation) error {
+	shard := sc.getShard(op.Key)
+
+	switch op.Op {
+	case OpSet:
+		var expiration int64
+		if op.TTLNanos > 0 {
+			expiration = time.Now().Add(time.Duration(op.TTLNanos)).UnixNano()
+		}
+		value, err := decodeItemValue(op.Value)
+		if err != nil {
+			return err
+		}
+		return shard.setConditionalNoPublish(op.Key, value, expiration, int64(len(op.Value)), op.Cond)
+	case OpDelete:
+		return shard.deleteNoPublish(op.Key)
+	case OpIncr:
+		if len(op.Value) != 8 {
+			return fmt.Errorf("replication: malformed incr payload for key %q", op.Key)
+		}
+		delta := int64(binary.BigEndian.Uint64(op.Value))
+		_, err := shard.incrNoPublish(op.Key, delta)
+		return err
+	default:
+		return fmt.Errorf("replication: unknown op %d for key %q", op.Op, op.Key)
+	}
+}
+
+// ParseByteSize parses human-friendly byte sizes such as "64MB", "512KiB"
+// or a bare integer number of bytes.
+func ParseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix string
+		mult   int64
+	}{
+		{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+		{"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+		{"B", 1},
+	}
+
+	upper := strings.ToUpper(s)
+	for _, u := range units {
+		if strings.HasSuffix(upper, strings.ToUpper(u.suffix)) {
+			numPart := s[:len(s)-len(u.suffix)]
+			value, err := strconv.ParseFloat(strings.TrimSpace(numPart), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid byte size %q: %w", s, err)
+			}
+			return int64(value * float64(u.mult)), nil
+		}
+	}
+
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q: %w", s, err)
+	}
+	return value, nil
+}
+
+// approxSize estimates the in-memory cost of an item via its gob encoding.
+func approxSize(key string, value interface{}) int64 {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&item{Key: key, Value: value}); err != nil {
+		return int64(len(key))
+	}
+	return int64(buf.Len())
+}
+
 func (c *cacheShard) cleanupLoop(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -107,6 +294,8 @@ func (c *cacheShard) deleteExpired() {
 	// Шаг 2: Удаление
 	for _, key := range keysToDelete {
 		if elem, ok := c.items[key]; ok {
+			it := elem.Value.(*item)
+			c.bytes -= it.Size
 			c.ll.Remove(elem)
 			delete(c.items, key)
 		}
@@ -114,15 +303,40 @@ func (c *cacheShard) deleteExpired() {
 }
 
 func (c *cacheShard) incr(key string, delta int64) (int64, error) {
+	c.mu.Lock()
+	newValue, err := c.incrLocked(key, delta)
+	if err != nil {
+		c.mu.Unlock()
+		return 0, err
+	}
+	op, shouldPublish := c.prepareOp(OpIncr, key, encodeDelta(delta), 0)
+	c.mu.Unlock()
+
+	if shouldPublish {
+		c.publish(op)
+	}
+	return newValue, nil
+}
+
+// incrNoPublish applies an Incr/Decr delta received from the replication
+// log; it must not publish again or nodes would echo writes back at each
+// other.
+func (c *cacheShard) incrNoPublish(key string, delta int64) (int64, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.incrLocked(key, delta)
+}
+
+// incrLocked performs the actual increment/insert/evict; callers must hold c.mu.
+func (c *cacheShard) incrLocked(key string, delta int64) (int64, error) {
+	var newValue int64
+
 	elem, ok := c.items[key]
 	if ok {
 		c.ll.MoveToFront(elem)
 		it := elem.Value.(*item)
 
-		var newValue int64
 		switch v := it.Value.(type) {
 		case int64:
 			newValue = int64(v) + delta
@@ -133,58 +347,188 @@ func (c *cacheShard) incr(key string, delta int64) (int64, error) {
 		case int32:
 			newValue = int64(v) + delta
 			it.Value = newValue
+		case json.Number:
+			// A value restored from a codec=json snapshot: JSON has no
+			// integer type of its own, so it comes back as json.Number
+			// rather than int64.
+			n, err := v.Int64()
+			if err != nil {
+				return 0, errors.New("value is not an integer")
+			}
+			newValue = n + delta
+			it.Value = newValue
 		case string:
 			return 0, errors.New("value is not an integer")
 		default:
 			return 0, errors.New("value is not a supported number type")
 		}
 
-		return newValue, nil
-
+		c.bytes -= it.Size
+		it.Size = approxSize(key, it.Value)
+		c.bytes += it.Size
 	} else {
-		it := &item{Key: key, Value: delta, Expiration: 0}
+		newValue = delta
+		size := approxSize(key, newValue)
+		it := &item{Key: key, Value: newValue, Expiration: 0, Size: size}
 		elem := c.ll.PushFront(it)
 
 		c.items[key] = elem
+		c.bytes += size
 
-		if c.maxSize > 0 && c.ll.Len() > c.maxSize {
-			lruElement := c.ll.Back()
-			if lruElement != nil {
-				c.ll.Remove(lruElement)
-				lruItem := lruElement.Value.(*item)
-				delete(c.items, lruItem.Key)
-			}
-		}
-		return delta, nil
+		c.evict()
 	}
+
+	return newValue, nil
 }
 
 func (c *cacheShard) set(key string, value interface{}, expiration int64) error {
+	return c.setWithSize(key, value, expiration, approxSize(key, value))
+}
 
+func (c *cacheShard) setWithSize(key string, value interface{}, expiration int64, size int64) error {
+	c.mu.Lock()
+	c.setLocked(key, value, expiration, size)
+
+	var ttlNanos int64
+	if expiration > 0 {
+		ttlNanos = expiration - time.Now().UnixNano()
+	}
+	encoded, _ := encodeItemValue(value)
+	op, shouldPublish := c.prepareOp(OpSet, key, encoded, ttlNanos)
+	c.mu.Unlock()
+
+	if shouldPublish {
+		c.publish(op)
+	}
+	return nil
+}
+
+// setWithSizeNoPublish applies a mutation received from the replication log;
+// it must not publish again or nodes would echo writes back at each other.
+func (c *cacheShard) setWithSizeNoPublish(key string, value interface{}, expiration int64, size int64) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.setLocked(key, value, expiration, size)
+	return nil
+}
+
+// setConditional behaves like set but only applies if cond holds against
+// the shard's current state, checked and written under the same lock
+// acquisition so a racing setConditional on the same key can't slip in
+// between the check and the write.
+func (c *cacheShard) setConditional(key string, value interface{}, expiration int64, cond SetCondition) error {
+	c.mu.Lock()
+
+	size := approxSize(key, value)
+	if err := c.setConditionalLocked(key, value, expiration, size, cond); err != nil {
+		c.mu.Unlock()
+		return err
+	}
+
+	var ttlNanos int64
+	if expiration > 0 {
+		ttlNanos = expiration - time.Now().UnixNano()
+	}
+	encoded, _ := encodeItemValue(value)
+	op, shouldPublish := c.prepareOp(OpSet, key, encoded, ttlNanos)
+	c.mu.Unlock()
+
+	if shouldPublish {
+		c.publish(op)
+	}
+	return nil
+}
+
+// setConditionalNoPublish applies a conditional Set received from the
+// replication log; it must not publish again. Unlike an unconditional
+// replicated write, the condition can't be checked by the proposer
+// before it proposes: raft only guarantees every node applies committed
+// entries in the same order, not that proposals against the same key
+// arrive in any particular order, so two nodes racing a "SET k v NX"
+// against an absent key could both decide to propose. Evaluating cond
+// here, at apply time, means every node reaches the same decision about
+// which (if either) proposal actually took effect.
+func (c *cacheShard) setConditionalNoPublish(key string, value interface{}, expiration int64, size int64, cond SetCondition) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.setConditionalLocked(key, value, expiration, size, cond)
+}
+
+// setConditionalLocked checks cond against the shard's current state and,
+// if it holds, performs the insert/update/evict. Callers must hold c.mu.
+// It leaves the shard untouched and returns ErrConditionFailed if cond
+// isn't met.
+func (c *cacheShard) setConditionalLocked(key string, value interface{}, expiration int64, size int64, cond SetCondition) error {
+	if cond != CondNone {
+		exists := false
+		if elem, ok := c.items[key]; ok {
+			it := elem.Value.(*item)
+			exists = it.Expiration == 0 || time.Now().UnixNano() <= it.Expiration
+		}
+		if cond == CondNX && exists {
+			return ErrConditionFailed
+		}
+		if cond == CondXX && !exists {
+			return ErrConditionFailed
+		}
+	}
+
+	c.setLocked(key, value, expiration, size)
+	return nil
+}
+
+// setLocked performs the actual insert/update/evict; callers must hold c.mu.
+func (c *cacheShard) setLocked(key string, value interface{}, expiration int64, size int64) {
 	if elem, ok := c.items[key]; ok {
 		c.ll.MoveToFront(elem)
 		it := elem.Value.(*item)
+		c.bytes -= it.Size
 		it.Value = value
 		it.Expiration = expiration
+		it.Size = size
+		c.bytes += size
 	} else {
-		it := &item{Key: key, Value: value, Expiration: expiration}
+		it := &item{Key: key, Value: value, Expiration: expiration, Size: size}
 		elem := c.ll.PushFront(it)
 		c.items[key] = elem
+		c.bytes += size
 	}
 
-	if c.maxSize > 0 && c.ll.Len() > c.maxSize {
+	c.evict()
+}
+
+// encodeDelta packs an Incr/Decr delta as the 8-byte big-endian payload
+// carried on an Operation.
+func encodeDelta(delta int64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(delta))
+	return buf
+}
+
+// evict drops LRU entries, spilling them into the secondary store if one is
+// configured, until both the item-count and byte budgets are satisfied.
+// Callers must hold c.mu.
+func (c *cacheShard) evict() {
+	for (c.maxSize > 0 && c.ll.Len() > c.maxSize) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
 		lruElement := c.ll.Back()
-		if lruElement != nil {
-			c.ll.Remove(lruElement)
-			lruItem := lruElement.Value.(*item)
-			delete(c.items, lruItem.Key)
+		if lruElement == nil {
+			return
 		}
-	}
 
-	return nil
+		lruItem := lruElement.Value.(*item)
+
+		if c.secondary != nil {
+			if encoded, err := encodeItemValue(lruItem.Value); err == nil {
+				_ = c.secondary.Put(lruItem.Key, encoded, lruItem.Expiration)
+			}
+		}
+
+		c.ll.Remove(lruElement)
+		delete(c.items, lruItem.Key)
+		c.bytes -= lruItem.Size
+	}
 }
 
 func (c *cacheShard) get(key string) (interface{}, error) {
@@ -197,6 +541,7 @@ func (c *cacheShard) get(key string) (interface{}, error) {
 		if it.Expiration > 0 && time.Now().UnixNano() > it.Expiration {
 			c.ll.Remove(elem)
 			delete(c.items, key)
+			c.bytes -= it.Size
 			return nil, ErrNotFound
 		}
 
@@ -204,23 +549,178 @@ func (c *cacheShard) get(key string) (interface{}, error) {
 		return it.Value, nil
 	}
 
+	if c.secondary != nil {
+		encoded, err := c.secondary.Get(key)
+		if err == nil {
+			value, decodeErr := decodeItemValue(encoded)
+			if decodeErr == nil {
+				size := int64(len(encoded))
+				it := &item{Key: key, Value: value, Expiration: 0, Size: size}
+				elem := c.ll.PushFront(it)
+				c.items[key] = elem
+				c.bytes += size
+				c.evict()
+				return value, nil
+			}
+		}
+	}
+
 	return nil, ErrNotFound
 }
 
+// ttl returns the remaining time-to-live for key. A zero duration with a
+// nil error means the key exists but never expires.
+func (c *cacheShard) ttl(key string) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	it := elem.Value.(*item)
+	if it.Expiration > 0 && time.Now().UnixNano() > it.Expiration {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+		c.bytes -= it.Size
+		return 0, ErrNotFound
+	}
+
+	if it.Expiration == 0 {
+		return 0, nil
+	}
+	return time.Duration(it.Expiration - time.Now().UnixNano()), nil
+}
+
+// expire sets a new time-to-live on an existing key. It returns
+// ErrNotFound if the key isn't present.
+func (c *cacheShard) expire(key string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	it := elem.Value.(*item)
+	if ttl > 0 {
+		it.Expiration = time.Now().Add(ttl).UnixNano()
+	} else {
+		it.Expiration = 0
+	}
+	c.ll.MoveToFront(elem)
+
+	return nil
+}
+
 func (c *cacheShard) stop() {
 	close(c.stopCh)
 }
 
 func (c *cacheShard) delete(key string) error {
+	c.mu.Lock()
+	c.deleteLocked(key)
+	op, shouldPublish := c.prepareOp(OpDelete, key, nil, 0)
+	c.mu.Unlock()
+
+	if shouldPublish {
+		c.publish(op)
+	}
+	return nil
+}
+
+// deleteNoPublish applies a deletion received from the replication log; it
+// must not publish again or nodes would echo writes back at each other.
+func (c *cacheShard) deleteNoPublish(key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.deleteLocked(key)
+	return nil
+}
+
+// deleteLocked performs the actual removal; callers must hold c.mu.
+func (c *cacheShard) deleteLocked(key string) {
 	if elem, ok := c.items[key]; ok {
+		it := elem.Value.(*item)
+		c.bytes -= it.Size
 		c.ll.Remove(elem)
 		delete(c.items, key)
 	}
 
-	return nil
+	if c.secondary != nil {
+		_ = c.secondary.Delete(key)
+	}
+}
+
+// prepareOp assigns the next LSN and builds the Operation a mutation
+// should publish. Callers must hold c.mu so LSN assignment matches
+// mutation order. The second return value is false when no replicator is
+// configured, in which case the Operation is unset and publish must not
+// be called with it.
+func (c *cacheShard) prepareOp(opType OpType, key string, value []byte, ttlNanos int64) (Operation, bool) {
+	if c.replicator == nil {
+		return Operation{}, false
+	}
+
+	c.lsn++
+	return Operation{
+		Op:       opType,
+		Key:      key,
+		Value:    value,
+		TTLNanos: ttlNanos,
+		LSN:      c.lsn,
+		NodeID:   c.nodeID,
+	}, true
+}
+
+// publish ships op to the replication log. Callers must call it after
+// releasing c.mu: Replicator.Publish can block on a network call (e.g. a
+// slow or unreachable Kafka broker), and holding the shard lock across
+// that call would stall every other Get/Set/Delete on the shard for as
+// long as the broker does.
+func (c *cacheShard) publish(op Operation) {
+	if err := c.replicator.Publish(op); err != nil {
+		log.Printf("cache: publishing replicated op for key %q: %v", op.Key, err)
+	}
+}
+
+// NewSetOperation encodes value the same way Set publishes it, so an
+// external consensus log (cluster.Node.Propose) can carry the write and
+// ApplyReplicated can decode it identically on every node.
+func (sc *ShardedCache) NewSetOperation(key string, value interface{}, ttl time.Duration) (Operation, error) {
+	encoded, err := encodeItemValue(value)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	return Operation{Op: OpSet, Key: key, Value: encoded, TTLNanos: int64(ttl)}, nil
+}
+
+// NewConditionalSetOperation is NewSetOperation's SetConditional
+// counterpart, carrying cond for ApplyReplicated to evaluate atomically
+// at apply time.
+func (sc *ShardedCache) NewConditionalSetOperation(key string, value interface{}, ttl time.Duration, cond SetCondition) (Operation, error) {
+	encoded, err := encodeItemValue(value)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	return Operation{Op: OpSet, Key: key, Value: encoded, TTLNanos: int64(ttl), Cond: cond}, nil
+}
+
+// NewDeleteOperation builds the Operation ApplyReplicated's OpDelete case
+// expects.
+func (sc *ShardedCache) NewDeleteOperation(key string) Operation {
+	return Operation{Op: OpDelete, Key: key}
+}
+
+// NewIncrOperation builds the Operation ApplyReplicated's OpIncr case
+// expects; delta is negative for a decrement.
+func (sc *ShardedCache) NewIncrOperation(key string, delta int64) Operation {
+	return Operation{Op: OpIncr, Key: key, Value: encodeDelta(delta)}
 }
 
 func (sc *ShardedCache) Incr(key string) (int64, error) {
@@ -253,6 +753,30 @@ func (sc *ShardedCache) Set(key string, value interface{}, ttl time.Duration) er
 	return shard.set(key, value, expiration)
 }
 
+// SetConditional behaves like Set but only takes effect if cond holds,
+// evaluated atomically with the write.
+func (sc *ShardedCache) SetConditional(key string, value interface{}, ttl time.Duration, cond SetCondition) error {
+	var expiration int64
+	if ttl > 0 {
+		expiration = time.Now().Add(ttl).UnixNano()
+	}
+
+	shard := sc.getShard(key)
+	return shard.setConditional(key, value, expiration, cond)
+}
+
+// SetWithSize behaves like Set but lets the caller supply the byte cost
+// charged against maxBytes instead of estimating it via gob encoding.
+func (sc *ShardedCache) SetWithSize(key string, value interface{}, ttl time.Duration, size int64) error {
+	var expiration int64
+	if ttl > 0 {
+		expiration = time.Now().Add(ttl).UnixNano()
+	}
+
+	shard := sc.getShard(key)
+	return shard.setWithSize(key, value, expiration, size)
+}
+
 func (sc *ShardedCache) Get(key string) (interface{}, error) {
 	shard := sc.getShard(key)
 	return shard.get(key)
@@ -263,13 +787,128 @@ func (sc *ShardedCache) Delete(key string) error {
 	return shard.delete(key)
 }
 
+// TTL returns the remaining time-to-live for key. A zero duration with a
+// nil error means the key exists but never expires.
+func (sc *ShardedCache) TTL(key string) (time.Duration, error) {
+	shard := sc.getShard(key)
+	return shard.ttl(key)
+}
+
+// Expire sets a new time-to-live on an existing key.
+func (sc *ShardedCache) Expire(key string, ttl time.Duration) error {
+	shard := sc.getShard(key)
+	return shard.expire(key, ttl)
+}
+
 func (sc *ShardedCache) Stop() {
 	for _, shard := range sc.shards {
 		shard.stop()
 	}
 }
 
+// Info reports basic runtime stats, including item count and byte usage
+// across all shards.
+func (sc *ShardedCache) Info() map[string]string {
+	var itemCount int
+	var totalBytes, totalMaxBytes int64
+
+	for _, shard := range sc.shards {
+		shard.mu.RLock()
+		itemCount += shard.ll.Len()
+		totalBytes += shard.bytes
+		totalMaxBytes += shard.maxBytes
+		shard.mu.RUnlock()
+	}
+
+	return map[string]string{
+		"shard_count": strconv.Itoa(int(sc.shardCount)),
+		"item_count":  strconv.Itoa(itemCount),
+		"used_bytes":  strconv.FormatInt(totalBytes, 10),
+		"max_bytes":   strconv.FormatInt(totalMaxBytes, 10),
+	}
+}
+
+// GetConfig exposes the total size and byte budgets as strings, computed
+// from the first shard's budgets since they're uniform across shards.
+func (sc *ShardedCache) GetConfig() map[string]string {
+	if len(sc.shards) == 0 {
+		return map[string]string{}
+	}
+
+	shard := sc.shards[0]
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	return map[string]string{
+		"max-size":  strconv.Itoa(shard.maxSize * int(sc.shardCount)),
+		"max-bytes": strconv.FormatInt(shard.maxBytes*int64(sc.shardCount), 10),
+	}
+}
+
+// SetConfig applies a config parameter to every shard. Only max-size and
+// max-bytes are currently adjustable at runtime.
+func (sc *ShardedCache) SetConfig(param string, value string) error {
+	switch param {
+	case "max-size":
+		total, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("max-size must be an integer: %w", err)
+		}
+		perShard := total / int(sc.shardCount)
+		for _, shard := range sc.shards {
+			shard.mu.Lock()
+			shard.maxSize = perShard
+			shard.mu.Unlock()
+		}
+	case "max-bytes":
+		total, err := ParseByteSize(value)
+		if err != nil {
+			return err
+		}
+		perShard := total / int64(sc.shardCount)
+		for _, shard := range sc.shards {
+			shard.mu.Lock()
+			shard.maxBytes = perShard
+			shard.mu.Unlock()
+		}
+	default:
+		return fmt.Errorf("unknown config parameter %q", param)
+	}
+	return nil
+}
+
+// encodeItemValue gob-encodes a stored value for the secondary store.
+func encodeItemValue(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeItemValue reverses encodeItemValue.
+func decodeItemValue(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
 func (sc *ShardedCache) SaveToFile(path string) error {
+	data, err := sc.SaveToBytes()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// SaveToBytes gob-encodes every item into a single in-memory blob - the
+// same format SaveToFile writes to disk, just without a filesystem in
+// between. cluster.Node uses it to build a raft snapshot's payload: a
+// snapshot shipped to another node can't be a local file path, it has to
+// carry the FSM's actual bytes.
+func (sc *ShardedCache) SaveToBytes() ([]byte, error) {
 	itemsToSave := make(map[string]*item)
 
 	for _, shard := range sc.shards {
@@ -281,35 +920,40 @@ func (sc *ShardedCache) SaveToFile(path string) error {
 		shard.mu.RUnlock()
 	}
 
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := gob.NewEncoder(file)
-	if err := encoder.Encode(itemsToSave); err != nil {
-		return err
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(itemsToSave); err != nil {
+		return nil, err
 	}
-
-	return nil
+	return buf.Bytes(), nil
 }
 
 func (sc *ShardedCache) LoadFromFile(path string) error {
-	file, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	decoder := gob.NewDecoder(file)
+	return sc.LoadFromBytes(data)
+}
 
+// LoadFromBytes reverses SaveToBytes. It replaces the cache's current
+// contents rather than merging into them, matching the full-state-replace
+// semantics a raft snapshot install needs: a follower catching up must end
+// up with exactly what's in the snapshot, not a union of the old and new
+// state.
+func (sc *ShardedCache) LoadFromBytes(data []byte) error {
 	var itemsToLoad map[string]*item
-	err = decoder.Decode(&itemsToLoad)
-	if err != nil {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&itemsToLoad); err != nil {
 		return err
 	}
 
+	for _, shard := range sc.shards {
+		shard.mu.Lock()
+		shard.items = make(map[string]*list.Element)
+		shard.ll = list.New()
+		shard.bytes = 0
+		shard.mu.Unlock()
+	}
+
 	for _, it := range itemsToLoad {
 		key := it.Key
 		shard := sc.getShard(key)
@@ -317,6 +961,7 @@ func (sc *ShardedCache) LoadFromFile(path string) error {
 
 		elem := shard.ll.PushFront(it)
 		shard.items[key] = elem
+		shard.bytes += it.Size
 
 		shard.mu.Unlock()
 	}
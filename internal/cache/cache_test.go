@@ -1,8 +1,11 @@
 package cache_test
 
 import (
+	"encoding/json"
+	"errors"
 	"gocache/internal/cache"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -10,7 +13,7 @@ import (
 func TestShardedCache_LRU_Mechanics(t *testing.T) {
 	shardCount := uint32(1)
 	maxSize := 3
-	c := cache.NewShardedCache(shardCount, maxSize, 0)
+	c := cache.NewShardedCache(shardCount, maxSize, 0, 0)
 
 	c.Set("A", 1, 0) // Список: [A]
 	c.Set("B", 2, 0) // Список: [B, A]
@@ -40,7 +43,7 @@ func TestShardedCache_LRU_Mechanics(t *testing.T) {
 func TestSharedCache_Persistence(t *testing.T) {
 	// Arrange
 	path := filepath.Join(t.TempDir(), "cache.goc")
-	cache1 := cache.NewShardedCache(32, 10, 10*time.Second)
+	cache1 := cache.NewShardedCache(32, 10, 0, 10*time.Second)
 
 	keyValues := map[string]string{
 		"A": "A",
@@ -61,7 +64,7 @@ func TestSharedCache_Persistence(t *testing.T) {
 	cache1.Stop()
 
 	// Act 2 - загрузка из файла
-	cache2 := cache.NewShardedCache(32, 10, 10*time.Second)
+	cache2 := cache.NewShardedCache(32, 10, 0, 10*time.Second)
 	err = cache2.LoadFromFile(path)
 	if err != nil {
 		t.Fatal(err)
@@ -81,7 +84,7 @@ func TestSharedCache_Persistence(t *testing.T) {
 
 func TestShardedCache_IncrDecr(t *testing.T) {
 	// Arrange
-	shardedCache := cache.NewShardedCache(4, 100, 0)
+	shardedCache := cache.NewShardedCache(4, 100, 0, 0)
 
 	// Act 1 - incr
 	val, err := shardedCache.Incr("A")
@@ -133,3 +136,104 @@ func TestShardedCache_IncrDecr(t *testing.T) {
 		t.Fatal("should have errored")
 	}
 }
+
+func TestShardedCache_IncrAfterJSONSnapshotRestore(t *testing.T) {
+	// A codec=json snapshot decodes numbers into an interface{} field as
+	// json.Number, not int64 - this restore stands in for that, without
+	// pulling in internal/snapshot's codec machinery.
+	shardedCache := cache.NewShardedCache(1, 0, 0, 0)
+	if err := shardedCache.Set("A", json.Number("41"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := shardedCache.Incr("A")
+	if err != nil {
+		t.Fatalf("INCR on a json.Number value should work, got error: %v", err)
+	}
+	if val != 42 {
+		t.Fatalf("got %d, want 42", val)
+	}
+}
+
+func TestShardedCache_SetConditional_NXAndXX(t *testing.T) {
+	shardedCache := cache.NewShardedCache(1, 0, 0, 0)
+
+	if err := shardedCache.SetConditional("A", "v1", 0, cache.CondXX); !errors.Is(err, cache.ErrConditionFailed) {
+		t.Fatalf("XX on an absent key: got %v, want ErrConditionFailed", err)
+	}
+
+	if err := shardedCache.SetConditional("A", "v1", 0, cache.CondNX); err != nil {
+		t.Fatalf("NX on an absent key should have applied: %v", err)
+	}
+
+	if err := shardedCache.SetConditional("A", "v2", 0, cache.CondNX); !errors.Is(err, cache.ErrConditionFailed) {
+		t.Fatalf("NX on a present key: got %v, want ErrConditionFailed", err)
+	}
+
+	if err := shardedCache.SetConditional("A", "v3", 0, cache.CondXX); err != nil {
+		t.Fatalf("XX on a present key should have applied: %v", err)
+	}
+
+	value, err := shardedCache.Get("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.(string) != "v3" {
+		t.Fatalf("got %v, want v3", value)
+	}
+}
+
+func TestShardedCache_SetConditional_NXIsRaceFree(t *testing.T) {
+	// Many goroutines race a "SET A v NX" against the same absent key;
+	// exactly one may observe it as applied.
+	shardedCache := cache.NewShardedCache(1, 0, 0, 0)
+
+	const racers = 50
+	var wg sync.WaitGroup
+	var applied int
+	var mu sync.Mutex
+
+	wg.Add(racers)
+	for i := 0; i < racers; i++ {
+		go func() {
+			defer wg.Done()
+			if err := shardedCache.SetConditional("A", "v", 0, cache.CondNX); err == nil {
+				mu.Lock()
+				applied++
+				mu.Unlock()
+			} else if !errors.Is(err, cache.ErrConditionFailed) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if applied != 1 {
+		t.Fatalf("expected exactly 1 NX to apply, got %d", applied)
+	}
+}
+
+func TestShardedCache_SecondaryStoreOverflow(t *testing.T) {
+	// Arrange - a single shard so eviction order is deterministic, and a
+	// byte budget smaller than either item's encoded size, so each Set
+	// spills its item straight to L2.
+	shardedCache := cache.NewShardedCache(1, 0, 64, 0)
+	shardedCache.UseSecondaryStore(cache.NewFSStore(t.TempDir(), 1))
+
+	if err := shardedCache.Set("A", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := shardedCache.Set("B", "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act - "A" should no longer be resident in L1, but Get should still
+	// find it via the fall-through to the secondary store.
+	value, err := shardedCache.Get("A")
+	if err != nil {
+		t.Fatalf("'A' should be retrievable from the secondary store, got error: %v", err)
+	}
+	if value.(string)[0] != 'a' {
+		t.Fatalf("retrieved value from secondary store does not match, got %v", value)
+	}
+}
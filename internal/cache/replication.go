@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// OpType identifies the mutation a replicated Operation carries.
+type OpType uint8
+
+const (
+	OpSet OpType = iota + 1
+	OpDelete
+	OpIncr
+	OpDecr
+)
+
+// Operation is the compact, binary-encoded write-ahead log record shipped
+// to the replication log. Key is also used as the log message key so that
+// partitioning lines up with getShard, keeping per-key ordering intact.
+type Operation struct {
+	Op       OpType
+	Key      string
+	Value    []byte
+	TTLNanos int64
+	LSN      uint64
+	NodeID   string
+	// Cond constrains an OpSet to only take effect if the condition holds;
+	// it's the zero value (CondNone) for every other op, and for a plain
+	// unconditional Set.
+	Cond SetCondition
+}
+
+// Replicator ships local mutations to a write-ahead log and replays
+// mutations recorded by other nodes back into the cache.
+type Replicator interface {
+	// Publish appends op to the log. It's called while the owning shard's
+	// lock is still held, so implementations must not block on anything
+	// that could deadlock with a concurrent Get/Set on the same shard.
+	Publish(op Operation) error
+
+	// Replay drives apply with every Operation the log yields, in order,
+	// until ctx is canceled or an unrecoverable error occurs. Callers
+	// typically run Replay in its own goroutine after an initial
+	// catch-up pass.
+	Replay(ctx context.Context, apply func(Operation) error) error
+}
+
+// Marshal encodes an Operation as a compact, length-prefixed binary record:
+// NodeID, Op, Cond, Key, Value, TTLNanos and LSN, in that order.
+func (op Operation) Marshal() ([]byte, error) {
+	buf := make([]byte, 0, 1+8+8+len(op.NodeID)+len(op.Key)+len(op.Value)+12)
+
+	buf = appendString(buf, op.NodeID)
+	buf = append(buf, byte(op.Op))
+	buf = append(buf, byte(op.Cond))
+	buf = appendString(buf, op.Key)
+	buf = appendBytes(buf, op.Value)
+
+	var scratch [8]byte
+	binary.BigEndian.PutUint64(scratch[:], uint64(op.TTLNanos))
+	buf = append(buf, scratch[:]...)
+	binary.BigEndian.PutUint64(scratch[:], op.LSN)
+	buf = append(buf, scratch[:]...)
+
+	return buf, nil
+}
+
+// UnmarshalOperation reverses Operation.Marshal.
+func UnmarshalOperation(data []byte) (Operation, error) {
+	var op Operation
+	var rest []byte
+	var err error
+
+	op.NodeID, rest, err = readString(data)
+	if err != nil {
+		return op, err
+	}
+	if len(rest) < 1 {
+		return op, errors.New("replication: truncated operation record")
+	}
+	op.Op = OpType(rest[0])
+	rest = rest[1:]
+
+	if len(rest) < 1 {
+		return op, errors.New("replication: truncated operation record")
+	}
+	op.Cond = SetCondition(rest[0])
+	rest = rest[1:]
+
+	op.Key, rest, err = readString(rest)
+	if err != nil {
+		return op, err
+	}
+
+	op.Value, rest, err = readBytes(rest)
+	if err != nil {
+		return op, err
+	}
+
+	if len(rest) < 16 {
+		return op, errors.New("replication: truncated operation record")
+	}
+	op.TTLNanos = int64(binary.BigEndian.Uint64(rest[:8]))
+	op.LSN = binary.BigEndian.Uint64(rest[8:16])
+
+	return op, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	return appendBytes(buf, []byte(s))
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	var scratch [4]byte
+	binary.BigEndian.PutUint32(scratch[:], uint32(len(b)))
+	buf = append(buf, scratch[:]...)
+	return append(buf, b...)
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("replication: truncated length prefix")
+	}
+	n := binary.BigEndian.Uint32(data[:4])
+	data = data[4:]
+	if uint32(len(data)) < n {
+		return nil, nil, fmt.Errorf("replication: truncated field of length %d", n)
+	}
+	return data[:n], data[n:], nil
+}
+
+func readString(data []byte) (string, []byte, error) {
+	b, rest, err := readBytes(data)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(b), rest, nil
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// defaultPublishTimeout bounds how long Publish can block a caller (and,
+// transitively, the shard lock it's called under) waiting on a slow or
+// unreachable broker.
+const defaultPublishTimeout = 5 * time.Second
+
+// KafkaReplicator is a Replicator backed by a Kafka topic: Publish writes
+// through a kafka.Writer, Replay tails a kafka.Reader bound to a consumer
+// group so offsets survive restarts.
+type KafkaReplicator struct {
+	nodeID         string
+	publishTimeout time.Duration
+	writer         *kafka.Writer
+	reader         *kafka.Reader
+}
+
+// NewKafkaReplicator dials brokers and prepares a writer/reader pair for
+// topic. nodeID is stamped on every published Operation so Replay can skip
+// messages this node produced itself.
+func NewKafkaReplicator(brokers []string, topic, groupID, nodeID string) *KafkaReplicator {
+	return &KafkaReplicator{
+		nodeID:         nodeID,
+		publishTimeout: defaultPublishTimeout,
+		writer: &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    topic,
+			Balancer: &kafka.Hash{},
+		},
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: brokers,
+			Topic:   topic,
+			GroupID: groupID,
+		}),
+	}
+}
+
+// SetPublishTimeout overrides how long Publish will wait on the broker
+// before giving up; the default is defaultPublishTimeout.
+func (k *KafkaReplicator) SetPublishTimeout(d time.Duration) {
+	k.publishTimeout = d
+}
+
+func (k *KafkaReplicator) Publish(op Operation) error {
+	op.NodeID = k.nodeID
+
+	payload, err := op.Marshal()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), k.publishTimeout)
+	defer cancel()
+
+	return k.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(op.Key),
+		Value: payload,
+	})
+}
+
+// Bootstrap drains everything currently on the topic (the backlog a fresh
+// node needs to catch up on) and applies each op, then returns as soon as
+// the reader has no more lag. Call this once before Start, then run Replay
+// in a goroutine to keep tailing live writes.
+func (k *KafkaReplicator) Bootstrap(ctx context.Context, apply func(Operation) error) error {
+	for {
+		lag, err := k.reader.ReadLag(ctx)
+		if err != nil {
+			return fmt.Errorf("replication: reading lag: %w", err)
+		}
+		if lag == 0 {
+			return nil
+		}
+
+		if err := k.replayOne(ctx, apply); err != nil {
+			return err
+		}
+	}
+}
+
+func (k *KafkaReplicator) Replay(ctx context.Context, apply func(Operation) error) error {
+	for {
+		if err := k.replayOne(ctx, apply); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (k *KafkaReplicator) replayOne(ctx context.Context, apply func(Operation) error) error {
+	msg, err := k.reader.FetchMessage(ctx)
+	if err != nil {
+		return err
+	}
+
+	op, err := UnmarshalOperation(msg.Value)
+	if err != nil {
+		return fmt.Errorf("replication: decoding operation at offset %d: %w", msg.Offset, err)
+	}
+
+	if op.NodeID != k.nodeID {
+		if err := apply(op); err != nil {
+			return fmt.Errorf("replication: applying op %d for key %q: %w", op.Op, op.Key, err)
+		}
+	}
+
+	return k.reader.CommitMessages(ctx, msg)
+}
+
+func (k *KafkaReplicator) Close() error {
+	writeErr := k.writer.Close()
+	readErr := k.reader.Close()
+	if writeErr != nil {
+		return writeErr
+	}
+	return readErr
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOperation_MarshalUnmarshalRoundTrip(t *testing.T) {
+	op := Operation{
+		Op:       OpSet,
+		Key:      "some-key",
+		Value:    []byte("some-value"),
+		TTLNanos: int64(30 * time.Second),
+		LSN:      42,
+		NodeID:   "node-a",
+		Cond:     CondNX,
+	}
+
+	data, err := op.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := UnmarshalOperation(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Op != op.Op || got.Key != op.Key || !bytes.Equal(got.Value, op.Value) ||
+		got.TTLNanos != op.TTLNanos || got.LSN != op.LSN || got.NodeID != op.NodeID || got.Cond != op.Cond {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", got, op)
+	}
+}
+
+// blockingReplicator is a Replicator whose Publish blocks until released,
+// used to prove a shard's lock isn't held across the replication call.
+type blockingReplicator struct {
+	release chan struct{}
+	called  chan struct{}
+}
+
+func newBlockingReplicator() *blockingReplicator {
+	return &blockingReplicator{
+		release: make(chan struct{}),
+		called:  make(chan struct{}, 1),
+	}
+}
+
+func (b *blockingReplicator) Publish(op Operation) error {
+	b.called <- struct{}{}
+	<-b.release
+	return nil
+}
+
+func (b *blockingReplicator) Replay(ctx context.Context, apply func(Operation) error) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestShardedCache_SetDoesNotHoldLockAcrossPublish(t *testing.T) {
+	sc := NewShardedCache(1, 0, 0, 0)
+	replicator := newBlockingReplicator()
+	sc.UseReplicator(replicator, "node-a")
+
+	done := make(chan error, 1)
+	go func() { done <- sc.Set("A", "1", 0) }()
+
+	select {
+	case <-replicator.called:
+	case <-time.After(time.Second):
+		t.Fatal("Publish was never called")
+	}
+
+	// Set's own Publish call is now blocked; if the shard lock were still
+	// held across it, this concurrent Get on a different key would hang.
+	getDone := make(chan struct{})
+	go func() {
+		sc.Get("B")
+		close(getDone)
+	}()
+
+	select {
+	case <-getDone:
+	case <-time.After(time.Second):
+		t.Fatal("Get blocked while an unrelated key's Publish was in flight")
+	}
+
+	close(replicator.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
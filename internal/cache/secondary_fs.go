@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FSStore is a SecondaryStore backed by the local filesystem: one file per
+// key under baseDir/<shardIdx>/<hash-prefix>/<hex-digest-of-key>, fronted
+// by an 8-byte expiration header so a Get can honor TTLs without
+// consulting L1.
+type FSStore struct {
+	baseDir    string
+	shardCount uint32
+
+	mu       sync.Mutex
+	bytes    int64
+	maxBytes int64
+}
+
+// NewFSStore creates an fs-backed secondary store rooted at baseDir. The
+// directory is created on first Put if it doesn't already exist.
+func NewFSStore(baseDir string, shardCount uint32) *FSStore {
+	return &FSStore{baseDir: baseDir, shardCount: shardCount}
+}
+
+// SetMaxBytes caps the total size FSStore will hold on disk; once usage
+// reaches the budget, Put rejects new entries instead of growing baseDir
+// without bound. It walks any files already under baseDir first, so a
+// restart against a pre-populated store doesn't reset usage to zero and
+// let it grow past the budget again. A zero maxBytes disables the cap.
+func (f *FSStore) SetMaxBytes(maxBytes int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var used int64
+	err := filepath.Walk(f.baseDir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			used += info.Size()
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f.maxBytes = maxBytes
+	f.bytes = used
+	return nil
+}
+
+// path derives the on-disk location for key. The filename is a hex digest
+// of key rather than key itself, so a key containing "../" or an absolute
+// path can't escape baseDir; the literal key is only ever stored inside
+// the file's payload, not its path.
+func (f *FSStore) path(key string) string {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(key))
+	hash := hasher.Sum32()
+
+	shardIdx := hash % f.shardCount
+	prefix := fmt.Sprintf("%02x", byte(hash))
+
+	digest := sha256.Sum256([]byte(key))
+	filename := hex.EncodeToString(digest[:])
+
+	return filepath.Join(f.baseDir, fmt.Sprintf("%d", shardIdx), prefix, filename)
+}
+
+func (f *FSStore) Put(key string, value []byte, expiration int64) error {
+	path := f.path(key)
+	size := int64(len(value) + 8)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var previous int64
+	if info, err := os.Stat(path); err == nil {
+		previous = info.Size()
+	}
+	if f.maxBytes > 0 && f.bytes-previous+size > f.maxBytes {
+		return fmt.Errorf("cache: l2 store is at its %d byte budget, rejecting %q", f.maxBytes, key)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var header [8]byte
+	binary.BigEndian.PutUint64(header[:], uint64(expiration))
+
+	if _, err := file.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := file.Write(value); err != nil {
+		return err
+	}
+
+	f.bytes += size - previous
+	return nil
+}
+
+func (f *FSStore) Get(key string) ([]byte, error) {
+	file, err := os.Open(f.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var header [8]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil {
+		return nil, err
+	}
+	expiration := int64(binary.BigEndian.Uint64(header[:]))
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiration > 0 && expiration < time.Now().UnixNano() {
+		_ = f.Delete(key)
+		return nil, ErrNotFound
+	}
+
+	return data, nil
+}
+
+func (f *FSStore) Delete(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := f.path(key)
+	info, statErr := os.Stat(path)
+
+	err := os.Remove(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if statErr == nil {
+		f.bytes -= info.Size()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFSStore_PathStaysInsideBaseDir(t *testing.T) {
+	baseDir := t.TempDir()
+	store := NewFSStore(baseDir, 4)
+
+	for _, key := range []string{
+		"../../etc/passwd",
+		"../../../tmp/evil",
+		"/etc/passwd",
+		"a/../../b",
+	} {
+		path := store.path(key)
+
+		rel, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			t.Fatalf("path for key %q is not relative to baseDir: %v", key, err)
+		}
+		if strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) {
+			t.Fatalf("path for key %q escaped baseDir: %s", key, path)
+		}
+	}
+}
+
+func TestFSStore_PutGetRoundTripsTraversalKey(t *testing.T) {
+	store := NewFSStore(t.TempDir(), 4)
+
+	key := "../../../../etc/passwd"
+	if err := store.Put(key, []byte("payload"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := store.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "payload" {
+		t.Fatalf("got %q, want %q", value, "payload")
+	}
+}
+
+func TestFSStore_RejectsPutPastMaxBytes(t *testing.T) {
+	store := NewFSStore(t.TempDir(), 4)
+	if err := store.SetMaxBytes(20); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put("A", []byte("0123456789"), 0); err != nil {
+		t.Fatalf("first put should fit the budget: %v", err)
+	}
+	if err := store.Put("B", []byte("0123456789"), 0); err == nil {
+		t.Fatal("expected the second put to be rejected for exceeding the byte budget")
+	}
+
+	if _, err := store.Get("B"); err != ErrNotFound {
+		t.Fatalf("rejected put should not have been stored, got err %v", err)
+	}
+}
+
+func TestFSStore_SetMaxBytesCountsExistingFiles(t *testing.T) {
+	baseDir := t.TempDir()
+	store := NewFSStore(baseDir, 4)
+
+	if err := store.Put("A", []byte("0123456789"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second store instance over the same directory, as happens on
+	// restart, must see the existing file's bytes counted against the
+	// budget rather than starting from zero.
+	restarted := NewFSStore(baseDir, 4)
+	if err := restarted.SetMaxBytes(18); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restarted.Put("B", []byte("0123456789"), 0); err == nil {
+		t.Fatal("expected put to be rejected: existing file usage should already be close to the budget")
+	}
+}
@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"gocache/internal/snapshot"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotMagic      uint32 = 0x474f4341 // "GOCA"
+	snapshotFormatV1   uint16 = 1
+	currentSnapshotFmt        = snapshotFormatV1
+)
+
+// snapshotHeader precedes the per-shard payloads in a snapshot stream so
+// Load can reject a stream with a foreign magic number or an unsupported
+// format version before trying to decode anything. The codec is not
+// recorded in it, and a differing shard count is accepted (Load re-routes
+// every key).
+type snapshotHeader struct {
+	Magic      uint32
+	Format     uint16
+	ShardCount uint32
+	LSN        uint64
+}
+
+func (h snapshotHeader) write(w *bytes.Buffer) {
+	binary.Write(w, binary.BigEndian, h.Magic)
+	binary.Write(w, binary.BigEndian, h.Format)
+	binary.Write(w, binary.BigEndian, h.ShardCount)
+	binary.Write(w, binary.BigEndian, h.LSN)
+}
+
+func readSnapshotHeader(r *countingReader) (snapshotHeader, error) {
+	var h snapshotHeader
+	if err := binary.Read(r, binary.BigEndian, &h.Magic); err != nil {
+		return h, err
+	}
+	if h.Magic != snapshotMagic {
+		return h, fmt.Errorf("snapshot: bad magic %x, not a gocache snapshot", h.Magic)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.Format); err != nil {
+		return h, err
+	}
+	if h.Format != currentSnapshotFmt {
+		return h, fmt.Errorf("snapshot: unsupported format %d (this binary understands %d)", h.Format, currentSnapshotFmt)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.ShardCount); err != nil {
+		return h, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.LSN); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+// cacheSnapshotter is the default Snapshotter for a ShardedCache: it
+// encodes each shard's items through codec into that shard's own buffer,
+// without building one map of the whole cache. The encoded payloads of
+// all shards are held in memory together until Save has written them.
+type cacheSnapshotter struct {
+	sc    *ShardedCache
+	codec snapshot.Codec
+}
+
+// NewSnapshotter returns the default Snapshotter for sc, encoding shard
+// payloads with codec.
+func NewSnapshotter(sc *ShardedCache, codec snapshot.Codec) snapshot.Snapshotter {
+	return &cacheSnapshotter{sc: sc, codec: codec}
+}
+
+// Save writes a versioned header followed by one length-prefixed,
+// codec-encoded blob per shard. Shards are encoded concurrently into their
+// own small buffers, then flushed to w in shard order so the stream stays
+// deterministic.
+func (s *cacheSnapshotter) Save(ctx context.Context, w io.Writer) error {
+	var maxLSN uint64
+	for _, shard := range s.sc.shards {
+		shard.mu.RLock()
+		if shard.lsn > maxLSN {
+			maxLSN = shard.lsn
+		}
+		shard.mu.RUnlock()
+	}
+
+	header := snapshotHeader{
+		Magic:      snapshotMagic,
+		Format:     currentSnapshotFmt,
+		ShardCount: s.sc.shardCount,
+		LSN:        maxLSN,
+	}
+	var headerBuf bytes.Buffer
+	header.write(&headerBuf)
+	if _, err := w.Write(headerBuf.Bytes()); err != nil {
+		return err
+	}
+
+	encoded := make([][]byte, len(s.sc.shards))
+	errs := make([]error, len(s.sc.shards))
+
+	var wg sync.WaitGroup
+	for i, shard := range s.sc.shards {
+		wg.Add(1)
+		go func(i int, shard *cacheShard) {
+			defer wg.Done()
+
+			items := make(map[string]*item)
+			shard.mu.RLock()
+			for key, elem := range shard.items {
+				items[key] = elem.Value.(*item)
+			}
+			shard.mu.RUnlock()
+
+			var buf bytes.Buffer
+			if err := s.codec.Encode(&buf, items); err != nil {
+				errs[i] = err
+				return
+			}
+			encoded[i] = buf.Bytes()
+		}(i, shard)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("snapshot: encoding shard %d: %w", i, err)
+		}
+	}
+
+	for i, payload := range encoded {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var lenBuf [4]byte
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(payload)))
+		if _, err := w.Write(lenBuf[:]); err != nil {
+			return fmt.Errorf("snapshot: writing shard %d length: %w", i, err)
+		}
+		if _, err := w.Write(payload); err != nil {
+			return fmt.Errorf("snapshot: writing shard %d payload: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Load reverses Save. Keys are re-routed through getShard rather than
+// trusting the saved shard index, so a snapshot taken with a different
+// --shards count still loads correctly.
+func (s *cacheSnapshotter) Load(ctx context.Context, r io.Reader) error {
+	cr := &countingReader{r: r}
+
+	header, err := readSnapshotHeader(cr)
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < header.ShardCount; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var lenBuf [4]byte
+		if _, err := cr.Read(lenBuf[:]); err != nil {
+			return fmt.Errorf("snapshot: reading shard %d length: %w", i, err)
+		}
+		length := binary.BigEndian.Uint32(lenBuf[:])
+
+		payload := make([]byte, length)
+		if _, err := cr.Read(payload); err != nil {
+			return fmt.Errorf("snapshot: reading shard %d payload: %w", i, err)
+		}
+
+		var items map[string]*item
+		if err := s.codec.Decode(bytes.NewReader(payload), &items); err != nil {
+			return fmt.Errorf("snapshot: decoding shard %d: %w", i, err)
+		}
+
+		for key, it := range items {
+			shard := s.sc.getShard(key)
+			shard.mu.Lock()
+			elem := shard.ll.PushFront(it)
+			shard.items[key] = elem
+			shard.bytes += it.Size
+			shard.mu.Unlock()
+		}
+	}
+
+	return nil
+}
+
+// countingReader wraps an io.Reader with io.ReadFull semantics so partial
+// reads (common with network-backed readers) don't get misread as
+// truncated records.
+type countingReader struct {
+	r io.Reader
+}
+
+func (cr *countingReader) Read(buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := cr.r.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// UseSnapshotStore wires the store and snapshotter a periodic snapshot loop
+// uses. Call StartSnapshotLoop to actually begin snapshotting.
+func (sc *ShardedCache) UseSnapshotStore(store snapshot.SnapshotStore, snapshotter snapshot.Snapshotter) {
+	sc.snapshotStore = store
+	sc.snapshotter = snapshotter
+}
+
+// StartSnapshotLoop saves a snapshot on every tick of interval until ctx is
+// canceled. It's meant to run in its own goroutine.
+func (sc *ShardedCache) StartSnapshotLoop(ctx context.Context, interval time.Duration) {
+	if sc.snapshotStore == nil || sc.snapshotter == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := sc.SaveSnapshot(ctx); err != nil {
+				fmt.Println("snapshot: save failed:", err)
+			}
+		}
+	}
+}
+
+// SaveSnapshot saves a single snapshot named after the current time.
+func (sc *ShardedCache) SaveSnapshot(ctx context.Context) error {
+	name := fmt.Sprintf("snapshot-%d.goc", time.Now().UnixNano())
+
+	wc, err := sc.snapshotStore.Writer(name)
+	if err != nil {
+		return err
+	}
+
+	if err := sc.snapshotter.Save(ctx, wc); err != nil {
+		wc.Close()
+		return err
+	}
+
+	return wc.Close()
+}
+
+// LoadLatestSnapshot loads the most recent snapshot in the store, if any.
+// It's a no-op returning nil when the store has no snapshots yet.
+func (sc *ShardedCache) LoadLatestSnapshot(ctx context.Context) error {
+	names, err := sc.snapshotStore.List()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	sort.Strings(names)
+	latest := names[len(names)-1]
+
+	rc, err := sc.snapshotStore.Reader(latest)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return sc.snapshotter.Load(ctx, rc)
+}
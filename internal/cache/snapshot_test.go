@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSnapshotHeader_WriteRead(t *testing.T) {
+	h := snapshotHeader{
+		Magic:      snapshotMagic,
+		Format:     currentSnapshotFmt,
+		ShardCount: 8,
+		LSN:        123,
+	}
+
+	var buf bytes.Buffer
+	h.write(&buf)
+
+	got, err := readSnapshotHeader(&countingReader{r: &buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != h {
+		t.Fatalf("got %+v, want %+v", got, h)
+	}
+}
+
+func TestReadSnapshotHeader_RejectsBadMagic(t *testing.T) {
+	h := snapshotHeader{Magic: 0xdeadbeef, Format: currentSnapshotFmt, ShardCount: 1}
+
+	var buf bytes.Buffer
+	h.write(&buf)
+
+	if _, err := readSnapshotHeader(&countingReader{r: &buf}); err == nil {
+		t.Fatal("expected an error for a bad magic number")
+	}
+}
+
+func TestReadSnapshotHeader_RejectsUnsupportedFormat(t *testing.T) {
+	h := snapshotHeader{Magic: snapshotMagic, Format: currentSnapshotFmt + 1, ShardCount: 1}
+
+	var buf bytes.Buffer
+	h.write(&buf)
+
+	if _, err := readSnapshotHeader(&countingReader{r: &buf}); err == nil {
+		t.Fatal("expected an error for an unsupported format version")
+	}
+}
@@ -0,0 +1,612 @@
+// Package cluster turns a set of gocache nodes into a Raft-replicated
+// state machine, giving callers a strongly-consistent alternative to the
+// Kafka write-ahead log in internal/cache.
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gocache/internal/cache"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.etcd.io/etcd/raft/v3"
+	"go.etcd.io/etcd/raft/v3/raftpb"
+)
+
+// Files persisted under Config.RaftDir. Each is rewritten wholesale on
+// every update rather than appended/WAL-style. SnapshotThreshold keeps the
+// entries log small; the hard state is small but is rewritten on every
+// Ready that changes it, which includes each advance of the commit index;
+// the snapshot is rewritten once per SnapshotThreshold applied entries.
+const (
+	hardStateFile = "hardstate.pb"
+	snapshotFile  = "snapshot.pb"
+	entriesFile   = "entries.pb"
+)
+
+// Config describes a single node's place in the cluster.
+type Config struct {
+	ClusterID uint64
+	ID        uint64
+	// ListenAddr is this node's own "host:port" for the raft transport.
+	ListenAddr string
+	// Peers maps every other member's raft ID to its "host:port". It must
+	// not include this node's own ID.
+	Peers map[uint64]string
+	// SnapshotThreshold is how many applied entries accumulate before the
+	// FSM's SaveToBytes-backed snapshot is taken and the log compacted.
+	SnapshotThreshold uint64
+	// RaftDir, if set, is where this node persists its raft hard state,
+	// log entries, and latest snapshot, so a restart resumes from them
+	// instead of rejoining with an empty log. Leave it empty to keep
+	// storage purely in-memory (everything is lost on restart; the node
+	// must catch up from a peer instead).
+	RaftDir string
+}
+
+// Node drives a single raft group whose FSM is a *cache.ShardedCache:
+// committed entries are decoded back into cache.Operation values and
+// applied via cache.ShardedCache.ApplyReplicated, the same entry point
+// the Kafka replicator uses, so both replication strategies share one
+// "apply a replayed write" code path.
+type Node struct {
+	id        uint64
+	clusterID uint64
+	cache     *cache.ShardedCache
+
+	raftNode raft.Node
+	storage  *raft.MemoryStorage
+	transport *transport
+
+	snapshotThreshold uint64
+	appliedIndex      uint64
+	confState         raftpb.ConfState
+	raftDir           string
+
+	mu      sync.Mutex
+	pending map[uint64]chan error
+
+	proposalSeq uint64
+
+	readMu    sync.Mutex
+	readWait  map[string]chan uint64
+}
+
+// NewNode starts the raft machinery for cfg but does not yet run its event
+// loop; call Run in its own goroutine to start ticking and applying
+// entries.
+func NewNode(cfg Config, sc *cache.ShardedCache) (*Node, error) {
+	storage := raft.NewMemoryStorage()
+
+	persisted, err := loadPersistedState(cfg.RaftDir)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: loading persisted raft state from %s: %w", cfg.RaftDir, err)
+	}
+	isRestart := persisted.snapshot != nil || len(persisted.entries) > 0 || !raft.IsEmptyHardState(persisted.hardState)
+
+	var appliedIndex uint64
+	var confState raftpb.ConfState
+	if persisted.snapshot != nil {
+		if err := storage.ApplySnapshot(*persisted.snapshot); err != nil {
+			return nil, fmt.Errorf("cluster: applying persisted snapshot: %w", err)
+		}
+		if err := sc.LoadFromBytes(persisted.snapshot.Data); err != nil {
+			return nil, fmt.Errorf("cluster: restoring FSM from persisted snapshot: %w", err)
+		}
+		appliedIndex = persisted.snapshot.Metadata.Index
+		confState = persisted.snapshot.Metadata.ConfState
+	}
+	if len(persisted.entries) > 0 {
+		if err := storage.Append(persisted.entries); err != nil {
+			return nil, fmt.Errorf("cluster: restoring persisted log entries: %w", err)
+		}
+	}
+	if !raft.IsEmptyHardState(persisted.hardState) {
+		if err := storage.SetHardState(persisted.hardState); err != nil {
+			return nil, fmt.Errorf("cluster: restoring persisted hard state: %w", err)
+		}
+	}
+
+	peerIDs := []raft.Peer{{ID: cfg.ID}}
+	for id := range cfg.Peers {
+		peerIDs = append(peerIDs, raft.Peer{ID: id})
+	}
+
+	raftCfg := &raft.Config{
+		ID:              cfg.ID,
+		ElectionTick:    10,
+		HeartbeatTick:   1,
+		Storage:         storage,
+		MaxSizePerMsg:   1024 * 1024,
+		MaxInflightMsgs: 256,
+		// Applied must only be set when restarting from persisted state;
+		// it tells raft not to redeliver entries this node already
+		// applied before the restart.
+		Applied: appliedIndex,
+	}
+
+	threshold := cfg.SnapshotThreshold
+	if threshold == 0 {
+		threshold = 10000
+	}
+
+	n := &Node{
+		id:                cfg.ID,
+		clusterID:         cfg.ClusterID,
+		cache:             sc,
+		storage:           storage,
+		transport:         newTransport(cfg.Peers),
+		snapshotThreshold: threshold,
+		appliedIndex:      appliedIndex,
+		confState:         confState,
+		raftDir:           cfg.RaftDir,
+		pending:           make(map[uint64]chan error),
+		readWait:          make(map[string]chan uint64),
+	}
+
+	if err := n.transport.listen(cfg.ListenAddr); err != nil {
+		return nil, fmt.Errorf("cluster: listening on %s: %w", cfg.ListenAddr, err)
+	}
+
+	if isRestart {
+		n.raftNode = raft.RestartNode(raftCfg)
+	} else {
+		n.raftNode = raft.StartNode(raftCfg, peerIDs)
+	}
+
+	return n, nil
+}
+
+// Run is the main event loop: it ticks the raft state machine, applies
+// committed entries to the cache, and ships outbound messages over the
+// transport. It blocks until ctx is canceled.
+func (n *Node) Run(ctx context.Context) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.raftNode.Stop()
+			return
+
+		case <-ticker.C:
+			n.raftNode.Tick()
+
+		case msg := <-n.transport.recvC:
+			if err := n.raftNode.Step(ctx, msg); err != nil {
+				log.Printf("cluster: step failed: %v", err)
+			}
+
+		case rd := <-n.raftNode.Ready():
+			if !raft.IsEmptySnap(rd.Snapshot) {
+				n.applySnapshot(rd.Snapshot)
+			}
+
+			if err := n.storage.Append(rd.Entries); err != nil {
+				log.Printf("cluster: appending log entries: %v", err)
+			}
+			n.persistEntries()
+
+			n.transport.send(rd.Messages)
+
+			for _, rs := range rd.ReadStates {
+				n.resolveReadState(rs)
+			}
+
+			for _, entry := range rd.CommittedEntries {
+				n.applyEntry(entry)
+			}
+
+			if !raft.IsEmptyHardState(rd.HardState) {
+				n.persistHardState(rd.HardState)
+			}
+
+			n.raftNode.Advance()
+
+			n.maybeSnapshot()
+		}
+	}
+}
+
+func (n *Node) applyEntry(entry raftpb.Entry) {
+	defer func() { n.appliedIndex = entry.Index }()
+
+	switch entry.Type {
+	case raftpb.EntryNormal:
+		if len(entry.Data) == 0 {
+			return
+		}
+
+		op, err := cache.UnmarshalOperation(entry.Data)
+		if err != nil {
+			log.Printf("cluster: decoding committed entry %d: %v", entry.Index, err)
+			return
+		}
+
+		err = n.cache.ApplyReplicated(op)
+		n.resolvePending(op.LSN, err)
+
+	case raftpb.EntryConfChange:
+		var cc raftpb.ConfChange
+		if err := cc.Unmarshal(entry.Data); err != nil {
+			log.Printf("cluster: decoding conf change %d: %v", entry.Index, err)
+			return
+		}
+
+		if cs := n.raftNode.ApplyConfChange(cc); cs != nil {
+			n.confState = *cs
+		}
+
+		switch cc.Type {
+		case raftpb.ConfChangeAddNode:
+			// The initial peers passed to raft.StartNode surface here too,
+			// as bootstrap ConfChange entries with no Context - their
+			// addresses are already known from Config.Peers, so only a
+			// genuine CLUSTER ADD (which always carries one) should update
+			// the transport's peer table.
+			if len(cc.Context) > 0 {
+				n.transport.addPeer(cc.NodeID, string(cc.Context))
+			}
+		case raftpb.ConfChangeRemoveNode:
+			n.transport.removePeer(cc.NodeID)
+		}
+
+		n.resolvePending(cc.ID, nil)
+	}
+}
+
+// applySnapshot installs a raft-delivered snapshot into local storage and
+// restores the FSM from its payload. Data is the sending node's
+// ShardedCache serialized via SaveToBytes - the same bytes maybeSnapshot
+// produces locally - so a lagging or brand-new follower converges without
+// replaying the full log.
+func (n *Node) applySnapshot(snap raftpb.Snapshot) {
+	if err := n.storage.ApplySnapshot(snap); err != nil {
+		log.Printf("cluster: applying snapshot at index %d: %v", snap.Metadata.Index, err)
+		return
+	}
+	if err := n.cache.LoadFromBytes(snap.Data); err != nil {
+		log.Printf("cluster: restoring FSM from snapshot at index %d: %v", snap.Metadata.Index, err)
+		return
+	}
+
+	n.appliedIndex = snap.Metadata.Index
+	n.confState = snap.Metadata.ConfState
+	n.persistSnapshot(snap)
+}
+
+func (n *Node) resolvePending(id uint64, err error) {
+	n.mu.Lock()
+	ch, ok := n.pending[id]
+	if ok {
+		delete(n.pending, id)
+	}
+	n.mu.Unlock()
+
+	if ok {
+		ch <- err
+	}
+}
+
+// maybeSnapshot compacts the raft log once appliedIndex has advanced far
+// enough past the last snapshot, reusing ShardedCache.SaveToBytes as the
+// FSM's snapshot surface. The snapshot's Data is the actual serialized
+// FSM, not a local file path, so raft can ship it to a follower that
+// needs to catch up via applySnapshot.
+func (n *Node) maybeSnapshot() {
+	firstIndex, err := n.storage.FirstIndex()
+	if err != nil {
+		return
+	}
+	if n.appliedIndex < firstIndex+n.snapshotThreshold {
+		return
+	}
+
+	data, err := n.cache.SaveToBytes()
+	if err != nil {
+		log.Printf("cluster: snapshotting FSM: %v", err)
+		return
+	}
+
+	snap, err := n.storage.CreateSnapshot(n.appliedIndex, &n.confState, data)
+	if err != nil {
+		log.Printf("cluster: creating raft snapshot: %v", err)
+		return
+	}
+	if err := n.storage.Compact(n.appliedIndex); err != nil {
+		log.Printf("cluster: compacting raft log: %v", err)
+		return
+	}
+
+	n.persistSnapshot(snap)
+	n.persistEntries()
+}
+
+// persistHardState writes hs to --raft-dir, overwriting whatever was
+// there. It's a no-op when RaftDir wasn't configured.
+func (n *Node) persistHardState(hs raftpb.HardState) {
+	if n.raftDir == "" {
+		return
+	}
+	data, err := hs.Marshal()
+	if err != nil {
+		log.Printf("cluster: marshaling hard state: %v", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(n.raftDir, hardStateFile), data, 0o644); err != nil {
+		log.Printf("cluster: persisting hard state: %v", err)
+	}
+}
+
+// persistSnapshot writes snap to --raft-dir, overwriting any prior one.
+// It's a no-op when RaftDir wasn't configured.
+func (n *Node) persistSnapshot(snap raftpb.Snapshot) {
+	if n.raftDir == "" {
+		return
+	}
+	data, err := snap.Marshal()
+	if err != nil {
+		log.Printf("cluster: marshaling snapshot: %v", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(n.raftDir, snapshotFile), data, 0o644); err != nil {
+		log.Printf("cluster: persisting snapshot: %v", err)
+	}
+}
+
+// persistEntries rewrites --raft-dir's entries log with whatever entries
+// n.storage currently holds past its last snapshot. It re-serializes the
+// whole in-memory log on every call rather than appending incrementally;
+// that's only affordable because SnapshotThreshold keeps the log this
+// covers small. It's a no-op when RaftDir wasn't configured.
+func (n *Node) persistEntries() {
+	if n.raftDir == "" {
+		return
+	}
+
+	first, err := n.storage.FirstIndex()
+	if err != nil {
+		log.Printf("cluster: reading first index to persist: %v", err)
+		return
+	}
+	last, err := n.storage.LastIndex()
+	if err != nil {
+		log.Printf("cluster: reading last index to persist: %v", err)
+		return
+	}
+
+	var entries []raftpb.Entry
+	if last >= first {
+		entries, err = n.storage.Entries(first, last+1, math.MaxUint64)
+		if err != nil {
+			log.Printf("cluster: reading entries to persist: %v", err)
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		data, err := e.Marshal()
+		if err != nil {
+			log.Printf("cluster: marshaling entry %d to persist: %v", e.Index, err)
+			return
+		}
+
+		var lenBuf [4]byte
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(data)))
+		buf.Write(lenBuf[:])
+		buf.Write(data)
+	}
+
+	if err := os.WriteFile(filepath.Join(n.raftDir, entriesFile), buf.Bytes(), 0o644); err != nil {
+		log.Printf("cluster: persisting entries: %v", err)
+	}
+}
+
+// persistedState is what loadPersistedState recovers from --raft-dir: the
+// hard state, log entries, and latest snapshot (if any) a prior process
+// instance left behind.
+type persistedState struct {
+	hardState raftpb.HardState
+	entries   []raftpb.Entry
+	snapshot  *raftpb.Snapshot
+}
+
+// loadPersistedState reads back whatever persistHardState/persistEntries/
+// persistSnapshot wrote to raftDir. An empty raftDir, or one with nothing
+// in it yet, returns a zero persistedState and a nil error - both mean
+// "starting a fresh raft log," which NewNode tells apart from a genuine
+// restart by checking whether any of the three fields came back non-empty.
+func loadPersistedState(raftDir string) (persistedState, error) {
+	var ps persistedState
+	if raftDir == "" {
+		return ps, nil
+	}
+
+	if data, err := os.ReadFile(filepath.Join(raftDir, snapshotFile)); err == nil {
+		var snap raftpb.Snapshot
+		if err := snap.Unmarshal(data); err != nil {
+			return ps, fmt.Errorf("unmarshaling persisted snapshot: %w", err)
+		}
+		ps.snapshot = &snap
+	} else if !os.IsNotExist(err) {
+		return ps, fmt.Errorf("reading persisted snapshot: %w", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(raftDir, hardStateFile)); err == nil {
+		if err := ps.hardState.Unmarshal(data); err != nil {
+			return ps, fmt.Errorf("unmarshaling persisted hard state: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return ps, fmt.Errorf("reading persisted hard state: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(raftDir, entriesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ps, nil
+		}
+		return ps, fmt.Errorf("reading persisted entries: %w", err)
+	}
+
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return ps, errors.New("truncated persisted entry length prefix")
+		}
+		n := binary.BigEndian.Uint32(data[:4])
+		data = data[4:]
+		if uint32(len(data)) < n {
+			return ps, errors.New("truncated persisted entry record")
+		}
+
+		var e raftpb.Entry
+		if err := e.Unmarshal(data[:n]); err != nil {
+			return ps, fmt.Errorf("unmarshaling persisted entry: %w", err)
+		}
+		ps.entries = append(ps.entries, e)
+		data = data[n:]
+	}
+
+	return ps, nil
+}
+
+// Propose submits op to the raft log and blocks until it's been committed
+// and applied to the local FSM (or ctx is canceled). The server only
+// replies to its client once this returns successfully, matching the
+// "only reply OK after the shard mutation succeeds" rule the Kafka
+// replicator follows.
+func (n *Node) Propose(ctx context.Context, op cache.Operation) error {
+	op.LSN = atomic.AddUint64(&n.proposalSeq, 1)
+
+	ch := make(chan error, 1)
+	n.mu.Lock()
+	n.pending[op.LSN] = ch
+	n.mu.Unlock()
+
+	data, err := op.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := n.raftNode.Propose(ctx, data); err != nil {
+		n.mu.Lock()
+		delete(n.pending, op.LSN)
+		n.mu.Unlock()
+		return err
+	}
+
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReadIndexLinear blocks until the local FSM has applied at least as far
+// as the cluster's committed read index, guaranteeing a subsequent local
+// Get observes every write acknowledged before this call. It backs the
+// server's "CONSISTENCY LINEAR" command.
+func (n *Node) ReadIndexLinear(ctx context.Context) error {
+	rctx := fmt.Sprintf("%d-%d", n.id, atomic.AddUint64(&n.proposalSeq, 1))
+
+	ch := make(chan uint64, 1)
+	n.readMu.Lock()
+	n.readWait[rctx] = ch
+	n.readMu.Unlock()
+
+	if err := n.raftNode.ReadIndex(ctx, []byte(rctx)); err != nil {
+		n.readMu.Lock()
+		delete(n.readWait, rctx)
+		n.readMu.Unlock()
+		return err
+	}
+
+	var readIndex uint64
+	select {
+	case readIndex = <-ch:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for n.appliedIndex < readIndex {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	return nil
+}
+
+func (n *Node) resolveReadState(rs raft.ReadState) {
+	rctx := string(rs.RequestCtx)
+
+	n.readMu.Lock()
+	ch, ok := n.readWait[rctx]
+	if ok {
+		delete(n.readWait, rctx)
+	}
+	n.readMu.Unlock()
+
+	if ok {
+		ch <- rs.Index
+	}
+}
+
+// AddMember proposes a configuration change adding id at addr and blocks
+// until it's committed. It backs the server's "CLUSTER ADD" command.
+func (n *Node) AddMember(ctx context.Context, id uint64, addr string) error {
+	return n.proposeConfChange(ctx, raftpb.ConfChange{
+		Type:    raftpb.ConfChangeAddNode,
+		NodeID:  id,
+		Context: []byte(addr),
+	})
+}
+
+// RemoveMember proposes a configuration change removing id and blocks
+// until it's committed. It backs the server's "CLUSTER REMOVE" command.
+func (n *Node) RemoveMember(ctx context.Context, id uint64) error {
+	return n.proposeConfChange(ctx, raftpb.ConfChange{
+		Type:   raftpb.ConfChangeRemoveNode,
+		NodeID: id,
+	})
+}
+
+func (n *Node) proposeConfChange(ctx context.Context, cc raftpb.ConfChange) error {
+	cc.ID = atomic.AddUint64(&n.proposalSeq, 1)
+
+	ch := make(chan error, 1)
+	n.mu.Lock()
+	n.pending[cc.ID] = ch
+	n.mu.Unlock()
+
+	if err := n.raftNode.ProposeConfChange(ctx, cc); err != nil {
+		n.mu.Lock()
+		delete(n.pending, cc.ID)
+		n.mu.Unlock()
+		return err
+	}
+
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ErrNotLeader is returned by callers that need to forward a write to the
+// leader; this package doesn't forward automatically.
+var ErrNotLeader = errors.New("cluster: this node is not the raft leader")
@@ -0,0 +1,248 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/raft/v3"
+
+	"gocache/internal/cache"
+)
+
+// freeAddr reserves an ephemeral TCP port on localhost and returns its
+// address, for handing to transport.listen in tests.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+// startHarnessNode builds a Node for a static 3-member bootstrap (every
+// node's Config.Peers lists the other two from the start) but, unlike
+// production startup, doesn't run it yet - the caller decides when to
+// start ticking it, which is how TestNode_FollowerCatchesUpViaSnapshot
+// simulates a node that's behind from the start.
+func startHarnessNode(t *testing.T, id uint64, addrs map[uint64]string, threshold uint64, raftDir string) *Node {
+	t.Helper()
+
+	peers := make(map[uint64]string)
+	for peerID, addr := range addrs {
+		if peerID != id {
+			peers[peerID] = addr
+		}
+	}
+
+	n, err := NewNode(Config{
+		ClusterID:         1,
+		ID:                id,
+		ListenAddr:        addrs[id],
+		Peers:             peers,
+		SnapshotThreshold: threshold,
+		RaftDir:           raftDir,
+	}, cache.NewShardedCache(1, 1000, 0, 0))
+	if err != nil {
+		t.Fatalf("starting node %d: %v", id, err)
+	}
+	return n
+}
+
+func waitForLeader(t *testing.T, nodes ...*Node) *Node {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, n := range nodes {
+			if status := n.raftNode.Status(); status.Lead == n.id {
+				return n
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatal("no leader elected within the deadline")
+	return nil
+}
+
+// TestNode_FollowerCatchesUpViaSnapshot builds a 3-node raft group,
+// deliberately never drives node2's event loop while node1 and node3
+// commit enough writes to trigger a snapshot and compact the log out
+// from under it, then starts node2 and checks it converges via an
+// installed snapshot rather than hanging forever waiting for log entries
+// the leader can no longer serve.
+func TestNode_FollowerCatchesUpViaSnapshot(t *testing.T) {
+	addrs := map[uint64]string{
+		1: freeAddr(t),
+		2: freeAddr(t),
+		3: freeAddr(t),
+	}
+
+	const snapshotThreshold = 2
+
+	node1 := startHarnessNode(t, 1, addrs, snapshotThreshold, "")
+	node2 := startHarnessNode(t, 2, addrs, snapshotThreshold, "")
+	node3 := startHarnessNode(t, 3, addrs, snapshotThreshold, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	// node2 is intentionally left undriven: nothing ticks it, steps
+	// inbound messages into it, or drains its Ready channel, so it can
+	// neither vote nor receive entries - standing in for a node that's
+	// behind from the moment the others start committing.
+	go node1.Run(ctx)
+	go node3.Run(ctx)
+
+	leader := waitForLeader(t, node1, node3)
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		op, err := leader.cache.NewSetOperation(key, fmt.Sprintf("value-%d", i), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		proposeCtx, proposeCancel := context.WithTimeout(ctx, 5*time.Second)
+		err = leader.Propose(proposeCtx, op)
+		proposeCancel()
+		if err != nil {
+			t.Fatalf("proposing %s: %v", key, err)
+		}
+	}
+
+	// The leader must have compacted at least once by now, or the test
+	// isn't exercising what it claims to.
+	if firstIndex, _ := leader.storage.FirstIndex(); firstIndex <= 1 {
+		t.Fatalf("leader never compacted its log (first index %d); snapshot install won't be exercised", firstIndex)
+	}
+
+	go node2.Run(ctx)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		value, err := node2.cache.Get("key-9")
+		if err == nil {
+			if value.(string) != "value-9" {
+				t.Fatalf("got %v, want value-9", value)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("node2 never caught up via a snapshot install within the deadline")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		value, err := node2.cache.Get(key)
+		if err != nil {
+			t.Fatalf("node2 missing %s after catching up: %v", key, err)
+		}
+		if want := fmt.Sprintf("value-%d", i); value.(string) != want {
+			t.Fatalf("node2's %s = %v, want %v", key, value, want)
+		}
+	}
+
+	if node2.appliedIndex == 0 {
+		t.Fatal("node2's appliedIndex should have advanced past the installed snapshot")
+	}
+}
+
+// TestNode_RestartRecoversFromRaftDir checks that a single-node cluster
+// configured with RaftDir survives a process restart: the second NewNode
+// call reads back the persisted hard state, log and snapshot instead of
+// starting from an empty log, and the FSM ends up with the same data.
+func TestNode_RestartRecoversFromRaftDir(t *testing.T) {
+	raftDir := t.TempDir()
+	addr := freeAddr(t)
+
+	n, err := NewNode(Config{
+		ClusterID:         1,
+		ID:                1,
+		ListenAddr:        addr,
+		SnapshotThreshold: 3,
+		RaftDir:           raftDir,
+	}, cache.NewShardedCache(1, 1000, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go n.Run(ctx)
+
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		op, err := n.cache.NewSetOperation(key, fmt.Sprintf("value-%d", i), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		proposeCtx, proposeCancel := context.WithTimeout(ctx, 5*time.Second)
+		err = n.Propose(proposeCtx, op)
+		proposeCancel()
+		if err != nil {
+			t.Fatalf("proposing %s: %v", key, err)
+		}
+	}
+
+	// Give the Ready loop a moment to persist the last write's hard
+	// state/entries after Propose's ack already returned.
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	ps, err := loadPersistedState(raftDir)
+	if err != nil {
+		t.Fatalf("loadPersistedState: %v", err)
+	}
+	if raft.IsEmptyHardState(ps.hardState) {
+		t.Fatal("expected a persisted hard state after committing writes")
+	}
+
+	addr2 := freeAddr(t)
+	restarted, err := NewNode(Config{
+		ClusterID:         1,
+		ID:                1,
+		ListenAddr:        addr2,
+		SnapshotThreshold: 3,
+		RaftDir:           raftDir,
+	}, cache.NewShardedCache(1, 1000, 0, 0))
+	if err != nil {
+		t.Fatalf("restarting node from %s: %v", raftDir, err)
+	}
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	t.Cleanup(cancel2)
+	go restarted.Run(ctx2)
+
+	// The restored snapshot/hard state may leave a few committed-but-not-
+	// yet-applied entries for the Ready loop to replay, so poll rather
+	// than asserting immediately.
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		want := fmt.Sprintf("value-%d", i)
+
+		for {
+			value, err := restarted.cache.Get(key)
+			if err == nil {
+				if value.(string) != want {
+					t.Fatalf("restarted node's %s = %v, want %v", key, value, want)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("restarted node missing %s after recovering from %s: %v", key, raftDir, err)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"go.etcd.io/etcd/raft/v3/raftpb"
+)
+
+// transport is a deliberately simple peer-to-peer link for raft messages:
+// one long-lived TCP connection per peer, each message framed as a
+// 4-byte big-endian length followed by its protobuf encoding. Real
+// deployments would want rafthttp's retry/backoff behavior; this is
+// enough to prove the cluster subsystem out on a LAN.
+type transport struct {
+	mu    sync.Mutex
+	peers map[uint64]string // id -> "host:port"
+	conns map[uint64]net.Conn
+
+	recvC chan raftpb.Message
+}
+
+func newTransport(peers map[uint64]string) *transport {
+	return &transport{
+		peers: peers,
+		conns: make(map[uint64]net.Conn),
+		recvC: make(chan raftpb.Message, 256),
+	}
+}
+
+// listen accepts inbound connections from peers and decodes messages off
+// each one into recvC.
+func (t *transport) listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go t.readLoop(conn)
+		}
+	}()
+
+	return nil
+}
+
+func (t *transport) readLoop(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(conn, lenBuf[:]); err != nil {
+			return
+		}
+		length := binary.BigEndian.Uint32(lenBuf[:])
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+
+		var msg raftpb.Message
+		if err := msg.Unmarshal(payload); err != nil {
+			log.Printf("cluster: dropping malformed message: %v", err)
+			continue
+		}
+
+		t.recvC <- msg
+	}
+}
+
+// send delivers messages to their destination peers, dialing lazily and
+// reusing the connection on subsequent sends. A send failure just drops
+// the message, matching raft's expectation that transport is unreliable
+// and it will retry via the next heartbeat/election timeout.
+func (t *transport) send(messages []raftpb.Message) {
+	for _, msg := range messages {
+		conn, err := t.conn(msg.To)
+		if err != nil {
+			continue
+		}
+
+		payload, err := msg.Marshal()
+		if err != nil {
+			continue
+		}
+
+		var lenBuf [4]byte
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(payload)))
+
+		if _, err := conn.Write(lenBuf[:]); err != nil {
+			t.dropConn(msg.To)
+			continue
+		}
+		if _, err := conn.Write(payload); err != nil {
+			t.dropConn(msg.To)
+		}
+	}
+}
+
+func (t *transport) conn(id uint64) (net.Conn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if conn, ok := t.conns[id]; ok {
+		return conn, nil
+	}
+
+	addr, ok := t.peers[id]
+	if !ok {
+		return nil, net.UnknownNetworkError("no known address for peer")
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t.conns[id] = conn
+	return conn, nil
+}
+
+func (t *transport) dropConn(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if conn, ok := t.conns[id]; ok {
+		conn.Close()
+		delete(t.conns, id)
+	}
+}
+
+// addPeer registers (or updates) a peer's address, used when CLUSTER ADD
+// brings in a new member after startup.
+func (t *transport) addPeer(id uint64, addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.peers[id] = addr
+}
+
+func (t *transport) removePeer(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.peers, id)
+	if conn, ok := t.conns[id]; ok {
+		conn.Close()
+		delete(t.conns, id)
+	}
+}
@@ -0,0 +1,68 @@
+package snapshot
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// CodecForName resolves a codec by its DSN query-string name. An empty
+// name defaults to gob, matching the format SaveToFile/LoadFromFile have
+// always used.
+func CodecForName(name string) (Codec, error) {
+	switch name {
+	case "", "gob":
+		return gobCodec{}, nil
+	case "json":
+		return jsonCodec{}, nil
+	case "msgpack":
+		return msgpackCodec{}, nil
+	default:
+		return nil, fmt.Errorf("snapshot: unknown codec %q", name)
+	}
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Encode(w io.Writer, v interface{}) error {
+	return gob.NewEncoder(w).Encode(v)
+}
+
+func (gobCodec) Decode(r io.Reader, v interface{}) error {
+	return gob.NewDecoder(r).Decode(v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// Decode uses UseNumber so a number decoded into an interface{} field -
+// every item.Value in a snapshot - comes back as a json.Number rather
+// than encoding/json's default float64, which would silently corrupt any
+// integer that happened to round-trip through this codec.
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	return msgpack.NewEncoder(w).Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	return msgpack.NewDecoder(r).Decode(v)
+}
@@ -0,0 +1,108 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestFileStore_WriterReaderListRoundTrip(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wc, err := store.Writer("snapshot-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wc.Write([]byte("payload")); err != nil {
+		t.Fatal(err)
+	}
+	if err := wc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "snapshot-1" {
+		t.Fatalf("got %v, want [snapshot-1]", names)
+	}
+
+	rc, err := store.Reader("snapshot-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(rc); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "payload" {
+		t.Fatalf("got %q, want %q", buf.String(), "payload")
+	}
+}
+
+func TestCodecs_EncodeDecodeRoundTrip(t *testing.T) {
+	for _, name := range []string{"", "gob", "json", "msgpack"} {
+		t.Run(name, func(t *testing.T) {
+			codec, err := CodecForName(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			in := map[string]interface{}{"A": "a-value", "B": int64(7)}
+
+			var buf bytes.Buffer
+			if err := codec.Encode(&buf, in); err != nil {
+				t.Fatal(err)
+			}
+
+			var out map[string]interface{}
+			if err := codec.Decode(&buf, &out); err != nil {
+				t.Fatal(err)
+			}
+
+			if out["A"] != "a-value" {
+				t.Fatalf("string field: got %v, want %v", out["A"], in["A"])
+			}
+		})
+	}
+}
+
+func TestJSONCodec_PreservesIntegerPrecision(t *testing.T) {
+	codec := jsonCodec{}
+
+	in := map[string]interface{}{"A": int64(42)}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+
+	var out map[string]interface{}
+	if err := codec.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	n, ok := out["A"].(json.Number)
+	if !ok {
+		t.Fatalf("expected a json.Number, got %T", out["A"])
+	}
+	got, err := n.Int64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42 {
+		t.Fatalf("got %d, want 42", got)
+	}
+}
+
+func TestCodecForName_UnknownReturnsError(t *testing.T) {
+	if _, err := CodecForName("yaml"); err == nil {
+		t.Fatal("expected an error for an unknown codec name")
+	}
+}
@@ -0,0 +1,47 @@
+package snapshot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileStore is a SnapshotStore backed by a local directory: one file per
+// snapshot name under baseDir.
+type FileStore struct {
+	baseDir string
+}
+
+// NewFileStore creates a FileStore rooted at baseDir, creating it if it
+// doesn't already exist.
+func NewFileStore(baseDir string) (*FileStore, error) {
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{baseDir: baseDir}, nil
+}
+
+func (f *FileStore) Writer(name string) (io.WriteCloser, error) {
+	return os.Create(filepath.Join(f.baseDir, name))
+}
+
+func (f *FileStore) Reader(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(f.baseDir, name))
+}
+
+func (f *FileStore) List() ([]string, error) {
+	entries, err := os.ReadDir(f.baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
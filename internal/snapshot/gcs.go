@@ -0,0 +1,65 @@
+package snapshot
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// GCSStore is a SnapshotStore backed by a Google Cloud Storage bucket.
+// Names are stored as objects under prefix.
+type GCSStore struct {
+	bucket *storage.BucketHandle
+	prefix string
+}
+
+// NewGCSStore dials GCS using application-default credentials and targets
+// bucket/prefix.
+func NewGCSStore(bucket, prefix string) (*GCSStore, error) {
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCSStore{
+		bucket: client.Bucket(bucket),
+		prefix: strings.Trim(prefix, "/"),
+	}, nil
+}
+
+func (g *GCSStore) key(name string) string {
+	if g.prefix == "" {
+		return name
+	}
+	return path.Join(g.prefix, name)
+}
+
+func (g *GCSStore) Writer(name string) (io.WriteCloser, error) {
+	return g.bucket.Object(g.key(name)).NewWriter(context.Background()), nil
+}
+
+func (g *GCSStore) Reader(name string) (io.ReadCloser, error) {
+	return g.bucket.Object(g.key(name)).NewReader(context.Background())
+}
+
+func (g *GCSStore) List() ([]string, error) {
+	var names []string
+
+	it := g.bucket.Objects(context.Background(), &storage.Query{Prefix: g.prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, strings.TrimPrefix(attrs.Name, g.prefix+"/"))
+	}
+
+	return names, nil
+}
@@ -0,0 +1,109 @@
+package snapshot
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Store is a SnapshotStore backed by an S3 (or S3-compatible) bucket.
+// Names are stored as objects under prefix.
+type S3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Store loads AWS config from the environment (credentials, region,
+// etc., same as every other AWS SDK v2 client) and targets bucket/prefix.
+func NewS3Store(bucket, prefix string) (*S3Store, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Store{
+		client: s3.NewFromConfig(cfg),
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}, nil
+}
+
+func (s *S3Store) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return path.Join(s.prefix, name)
+}
+
+// Writer streams the upload through an in-memory pipe so callers never
+// have to buffer the whole snapshot before it starts uploading.
+func (s *S3Store) Writer(name string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := s.client.PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s.key(name)),
+			Body:   pr,
+		})
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	return &s3UploadWriter{PipeWriter: pw, done: done}, nil
+}
+
+// s3UploadWriter makes Close wait for the goroutine PutObject runs in and
+// surface its error, instead of io.PipeWriter.Close's unconditional nil -
+// a failed upload must fail the caller's Close, the same way
+// storage.Writer.Close does for GCSStore.
+type s3UploadWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (w *s3UploadWriter) Close() error {
+	if err := w.PipeWriter.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+func (s *S3Store) Reader(name string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *S3Store) List() ([]string, error) {
+	var names []string
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			names = append(names, strings.TrimPrefix(aws.ToString(obj.Key), s.prefix+"/"))
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,72 @@
+// Package snapshot provides the storage and encoding abstractions that back
+// gocache's periodic persistence: where a snapshot is written to (local
+// disk, S3, GCS) and how its contents are encoded (gob, JSON, msgpack).
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Snapshotter knows how to serialize and restore whatever it's backing
+// (typically a *cache.ShardedCache) to and from a plain byte stream. The
+// stream's framing is entirely up to the implementation; Store/Codec only
+// decide where the bytes land and how individual values inside them are
+// encoded.
+type Snapshotter interface {
+	Save(ctx context.Context, w io.Writer) error
+	Load(ctx context.Context, r io.Reader) error
+}
+
+// SnapshotStore is a named-blob store a Snapshotter writes its stream to
+// and reads it back from. Names are opaque to the store; callers typically
+// use a timestamp so List can find the latest snapshot.
+type SnapshotStore interface {
+	Writer(name string) (io.WriteCloser, error)
+	Reader(name string) (io.ReadCloser, error)
+	List() ([]string, error)
+}
+
+// Codec encodes and decodes individual values within a snapshot stream.
+type Codec interface {
+	Name() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// NewStoreFromDSN builds a SnapshotStore and Codec from a DSN such as
+// "file:///var/lib/gocache/snapshots", "s3://my-bucket/gocache?codec=json"
+// or "gcs://my-bucket/gocache?codec=msgpack". The codec query parameter is
+// optional and defaults to gob.
+func NewStoreFromDSN(dsn string) (SnapshotStore, Codec, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("snapshot: invalid DSN %q: %w", dsn, err)
+	}
+
+	codec, err := CodecForName(u.Query().Get("codec"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "file", "":
+		path := u.Path
+		if u.Host != "" {
+			path = u.Host + path
+		}
+		store, err := NewFileStore(path)
+		return store, codec, err
+	case "s3":
+		store, err := NewS3Store(u.Host, strings.TrimPrefix(u.Path, "/"))
+		return store, codec, err
+	case "gcs":
+		store, err := NewGCSStore(u.Host, strings.TrimPrefix(u.Path, "/"))
+		return store, codec, err
+	default:
+		return nil, nil, fmt.Errorf("snapshot: unsupported scheme %q", u.Scheme)
+	}
+}